@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// WithAPIKeyAuth represents api key authentication adapter
+// It looks for api key in X-API-Key header (or the one set by WithAPIKeyHeader)
+// and optionally in a query param, verifies it against the credential
+// stored in provided store and if successful calls callback (if not nil)
+// to perform client side auth check. Authenticated principal is stored
+// to context (see PrincipalFromCtx).
+func WithAPIKeyAuth(store CredentialStore, callback AuthCallbackFunc, opts ...CredentialOption) http.Adapter {
+	cfg := newCredCfg(opts)
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			key := r.Header.Get(cfg.header)
+			if key == "" && cfg.query != "" {
+				key = r.URL.Query().Get(cfg.query)
+			}
+			if key == "" {
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("no api key found")),
+				)
+				return
+			}
+
+			id := cfg.keyID(key)
+
+			cred, err := VerifyCredential(ctx, store, cfg.hash, id, []byte(key))
+			if err != nil {
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusInternalServerError, fmt.Errorf("could not verify api key: %v", err)),
+				)
+				return
+			}
+			if cred == nil {
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("invalid api key")),
+				)
+				return
+			}
+
+			if callback != nil {
+				if err := callback(ctx, id, cred.Claims); err != nil {
+					respond.WithJSON(
+						w, r,
+						http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("unauthorized: %v", err)),
+					)
+					return
+				}
+			}
+
+			pid := cred.PrincipalID
+			if pid == "" {
+				pid = id
+			}
+
+			h(withPrincipal(ctx, &Principal{ID: pid, Scheme: "apikey", Claims: cred.Claims}), w, r)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package adapter_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+)
+
+func TestWithAPIKeyAuth(t *testing.T) {
+	sum := sha256.Sum256([]byte("kit_abc.s3cr3t"))
+
+	cases := []struct {
+		name     string
+		store    adapter.CredentialStore
+		opts     []adapter.CredentialOption
+		header   string
+		query    string
+		authErr  error
+		want     response
+		wantCode int
+	}{
+		{
+			name:     "test plain key",
+			store:    credStore{"key123": {PrincipalID: "billing", Secret: []byte("key123")}},
+			header:   "key123",
+			want:     response{Code: 200, Data: "billing"},
+			wantCode: 200,
+		},
+		{
+			name:  "test hashed key with id",
+			store: credStore{"kit_abc": {PrincipalID: "billing", Secret: []byte(hex.EncodeToString(sum[:]))}},
+			opts: []adapter.CredentialOption{
+				adapter.WithSecretHash(adapter.SHA256Secret),
+				adapter.WithAPIKeyID(func(key string) string { return key[:7] }),
+			},
+			header:   "kit_abc.s3cr3t",
+			want:     response{Code: 200, Data: "billing"},
+			wantCode: 200,
+		},
+		{
+			name:     "test query key",
+			store:    credStore{"key123": {PrincipalID: "billing", Secret: []byte("key123")}},
+			opts:     []adapter.CredentialOption{adapter.WithAPIKeyQuery("api_key")},
+			query:    "key123",
+			want:     response{Code: 200, Data: "billing"},
+			wantCode: 200,
+		},
+		{
+			name:     "test query key disabled",
+			store:    credStore{"key123": {PrincipalID: "billing", Secret: []byte("key123")}},
+			query:    "key123",
+			want:     response{Code: 401, Errors: []string{"no api key found"}},
+			wantCode: 401,
+		},
+		{
+			name:     "test unknown key",
+			store:    credStore{"key123": {PrincipalID: "billing", Secret: []byte("key123")}},
+			header:   "key456",
+			want:     response{Code: 401, Errors: []string{"invalid api key"}},
+			wantCode: 401,
+		},
+		{
+			name:     "test wrong secret",
+			store:    credStore{"kit_abc": {PrincipalID: "billing", Secret: []byte("kit_abc.other")}},
+			opts:     []adapter.CredentialOption{adapter.WithAPIKeyID(func(key string) string { return key[:7] })},
+			header:   "kit_abc.s3cr3t",
+			want:     response{Code: 401, Errors: []string{"invalid api key"}},
+			wantCode: 401,
+		},
+		{
+			name:     "test callback error",
+			store:    credStore{"key123": {PrincipalID: "billing", Secret: []byte("key123")}},
+			header:   "key123",
+			authErr:  fmt.Errorf("key revoked"),
+			want:     response{Code: 401, Errors: []string{"unauthorized: key revoked"}},
+			wantCode: 401,
+		},
+		{
+			name: "test store error",
+			store: adapter.CredentialStoreFunc(func(context.Context, string) (*adapter.Credential, error) {
+				return nil, fmt.Errorf("db down")
+			}),
+			header:   "key123",
+			want:     response{Code: 500, Errors: []string{"could not verify api key: db down"}},
+			wantCode: 500,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apt := adapter.WithAPIKeyAuth(
+				c.store,
+				func(ctx context.Context, id string, claims map[string]interface{}) error {
+					return c.authErr
+				},
+				c.opts...,
+			)
+
+			hdlr := apt(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				p := adapter.PrincipalFromCtx(ctx)
+				assert.Equal(t, "apikey", p.Scheme)
+				respond.WithJSON(w, r, p.ID)
+			})
+
+			req := httptest.NewRequest("GET", "/?api_key="+c.query, nil)
+			if c.header != "" {
+				req.Header.Set("X-API-Key", c.header)
+			}
+
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, req)
+
+			resp := response{}
+			json.NewDecoder(w.Body).Decode(&resp)
+
+			assert.Equal(t, c.want, resp)
+			assert.Equal(t, c.wantCode, w.Code)
+		})
+	}
+}
+
+type credStore map[string]*adapter.Credential
+
+func (s credStore) Credential(ctx context.Context, id string) (*adapter.Credential, error) {
+	return s[id], nil
+}
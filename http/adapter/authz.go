@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// AuthzMetaKey is the endpoint meta key authorization
+// requirements are listed under in the route table
+const AuthzMetaKey = "authz"
+
+// Policy represents authorization rule evaluated against
+// authenticated principal and the request
+type Policy interface {
+	// Allow reports whether principal is allowed to perform the request
+	Allow(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error)
+
+	// String describes the policy in the route table
+	String() string
+}
+
+// NewPolicy creates named Policy from provided func
+func NewPolicy(name string, f func(context.Context, *Principal, *gohttp.Request) (bool, error)) Policy {
+	return policy{name: name, allow: f}
+}
+
+type policy struct {
+	name  string
+	allow func(context.Context, *Principal, *gohttp.Request) (bool, error)
+}
+
+func (p policy) Allow(ctx context.Context, pr *Principal, r *gohttp.Request) (bool, error) {
+	return p.allow(ctx, pr, r)
+}
+
+func (p policy) String() string { return p.name }
+
+// RequireScopes represents authorization adapter allowing only
+// principals granted all of provided scopes (see HasScopes)
+func RequireScopes(scopes ...string) http.MetaAdapter {
+	return Require(HasScopes(scopes...))
+}
+
+// RequireRoles represents authorization adapter allowing only
+// principals having any of provided roles (see HasRoles)
+func RequireRoles(roles ...string) http.MetaAdapter {
+	return Require(HasRoles(roles...))
+}
+
+// Require represents authorization adapter allowing only principals
+// satisfying all of provided policies. Requests with no principal in
+// the context are answered with 401 and the ones failing a policy with 403.
+// Policies are listed in the route table under AuthzMetaKey, as long as
+// it is registered with endpoints or services (see http.MetaAdapter).
+//
+// Authentication adapter (eg. WithJWTAuth) needs to run first, so it
+// should either be set at the service level or come after Require
+// in the list of endpoint adapters.
+func Require(policies ...Policy) http.MetaAdapter {
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.String())
+	}
+
+	return http.WithMeta(http.Meta{AuthzMetaKey: names}, func(h http.HandlerFunc) http.HandlerFunc {
+		return func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			pr := PrincipalFromCtx(ctx)
+			if pr == nil {
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("authz: not authenticated")),
+				)
+				return
+			}
+
+			for _, p := range policies {
+				ok, err := p.Allow(ctx, pr, r)
+				if err != nil {
+					respond.WithJSON(
+						w, r,
+						http.NewError(gohttp.StatusInternalServerError, fmt.Errorf("authz: %v", err)),
+					)
+					return
+				}
+				if !ok {
+					respond.WithJSON(
+						w, r,
+						http.NewError(gohttp.StatusForbidden, fmt.Errorf("forbidden: %s required", p)),
+					)
+					return
+				}
+			}
+
+			h(ctx, w, r)
+		}
+	})
+}
+
+// HasScopes creates Policy satisfied by principals granted all of
+// provided scopes. Scopes are read from space delimited scope claim
+// (RFC 8693) or scp / scopes claims holding either a list or a string.
+func HasScopes(scopes ...string) Policy {
+	return NewPolicy(
+		fmt.Sprintf("scopes(%s)", strings.Join(scopes, ",")),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			granted := []string{}
+			for _, v := range claimValues(p.Claims, "scope", "scp", "scopes") {
+				granted = append(granted, strings.Fields(v)...)
+			}
+			for _, s := range scopes {
+				if !containsString(granted, s) {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+	)
+}
+
+// HasRoles creates Policy satisfied by principals having any of
+// provided roles. Roles are read from roles or role claims
+// holding either a list or a string.
+func HasRoles(roles ...string) Policy {
+	return NewPolicy(
+		fmt.Sprintf("roles(%s)", strings.Join(roles, "|")),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			granted := claimValues(p.Claims, "roles", "role")
+			for _, role := range roles {
+				if containsString(granted, role) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	)
+}
+
+// ClaimEquals creates Policy satisfied by principals whose claim
+// equals (or if it is a list, contains) provided value
+func ClaimEquals(claim, value string) Policy {
+	return NewPolicy(
+		fmt.Sprintf("claim(%s=%s)", claim, value),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			return containsString(claimValues(p.Claims, claim), value), nil
+		},
+	)
+}
+
+// ClaimMatchesVar creates Policy satisfied by principals whose claim
+// equals (or if it is a list, contains) the value of path variable, eg.
+// ClaimMatchesVar("tenant", "tenant") for /{tenant}/orders
+func ClaimMatchesVar(claim, pathVar string) Policy {
+	return NewPolicy(
+		fmt.Sprintf("claim(%s={%s})", claim, pathVar),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			v, ok := mux.Vars(r)[pathVar]
+			if !ok || v == "" {
+				return false, nil
+			}
+			return containsString(claimValues(p.Claims, claim), v), nil
+		},
+	)
+}
+
+// PrincipalMatchesVar creates Policy satisfied by principals whose
+// id equals the value of path variable, eg. PrincipalMatchesVar("id")
+// for /users/{id}
+func PrincipalMatchesVar(pathVar string) Policy {
+	return NewPolicy(
+		fmt.Sprintf("principal={%s}", pathVar),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			v, ok := mux.Vars(r)[pathVar]
+			return ok && v != "" && v == p.ID, nil
+		},
+	)
+}
+
+// AllOf creates Policy satisfied when all of provided policies are
+func AllOf(policies ...Policy) Policy {
+	return NewPolicy(
+		fmt.Sprintf("all(%s)", joinPolicies(policies)),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			for _, pl := range policies {
+				ok, err := pl.Allow(ctx, p, r)
+				if err != nil || !ok {
+					return false, err
+				}
+			}
+			return true, nil
+		},
+	)
+}
+
+// AnyOf creates Policy satisfied when any of provided policies is
+func AnyOf(policies ...Policy) Policy {
+	return NewPolicy(
+		fmt.Sprintf("any(%s)", joinPolicies(policies)),
+		func(ctx context.Context, p *Principal, r *gohttp.Request) (bool, error) {
+			for _, pl := range policies {
+				ok, err := pl.Allow(ctx, p, r)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	)
+}
+
+func joinPolicies(policies []Policy) string {
+	names := make([]string, len(policies))
+	for i, p := range policies {
+		names[i] = p.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// claimValues returns values of the first of provided claims found
+func claimValues(claims map[string]interface{}, names ...string) []string {
+	for _, n := range names {
+		switch v := claims[n].(type) {
+		case string:
+			return []string{v}
+		case []string:
+			return v
+		case []interface{}:
+			vals := make([]string, 0, len(v))
+			for _, e := range v {
+				vals = append(vals, fmt.Sprint(e))
+			}
+			return vals
+		case nil:
+			continue
+		default:
+			return []string{fmt.Sprint(v)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package adapter_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+)
+
+func TestRequire(t *testing.T) {
+	cases := []struct {
+		name     string
+		claims   map[string]interface{}
+		noAuth   bool
+		path     string
+		policies []adapter.Policy
+		want     response
+		wantCode int
+	}{
+		{
+			name:     "test scopes from scope claim",
+			claims:   map[string]interface{}{"scope": "orders:read orders:write"},
+			policies: []adapter.Policy{adapter.HasScopes("orders:write", "orders:read")},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name:     "test scopes from scp claim",
+			claims:   map[string]interface{}{"scp": []interface{}{"orders:read"}},
+			policies: []adapter.Policy{adapter.HasScopes("orders:read")},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name:     "test missing scope",
+			claims:   map[string]interface{}{"scope": "orders:read"},
+			policies: []adapter.Policy{adapter.HasScopes("orders:read", "orders:write")},
+			want:     response{Code: 403, Errors: []string{"forbidden: scopes(orders:read,orders:write) required"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test any role",
+			claims:   map[string]interface{}{"roles": []interface{}{"ops"}},
+			policies: []adapter.Policy{adapter.HasRoles("admin", "ops")},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name:     "test missing role",
+			claims:   map[string]interface{}{"role": "user"},
+			policies: []adapter.Policy{adapter.HasRoles("admin")},
+			want:     response{Code: 403, Errors: []string{"forbidden: roles(admin) required"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test claim matches path var",
+			claims:   map[string]interface{}{"tenant": "acme"},
+			policies: []adapter.Policy{adapter.ClaimMatchesVar("tenant", "tenant")},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name:     "test claim does not match path var",
+			claims:   map[string]interface{}{"tenant": "acme"},
+			path:     "/globex/orders/john",
+			policies: []adapter.Policy{adapter.ClaimMatchesVar("tenant", "tenant")},
+			want:     response{Code: 403, Errors: []string{"forbidden: claim(tenant={tenant}) required"}},
+			wantCode: 403,
+		},
+		{
+			name:   "test combined policies",
+			claims: map[string]interface{}{"tenant": "acme", "role": "user"},
+			path:   "/acme/orders/john",
+			policies: []adapter.Policy{
+				adapter.ClaimEquals("tenant", "acme"),
+				adapter.AnyOf(adapter.HasRoles("admin"), adapter.PrincipalMatchesVar("id")),
+			},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name:   "test combined policies failing",
+			claims: map[string]interface{}{"tenant": "acme", "role": "user"},
+			path:   "/acme/orders/jane",
+			policies: []adapter.Policy{
+				adapter.AllOf(
+					adapter.ClaimEquals("tenant", "acme"),
+					adapter.AnyOf(adapter.HasRoles("admin"), adapter.PrincipalMatchesVar("id")),
+				),
+			},
+			want: response{
+				Code:   403,
+				Errors: []string{"forbidden: all(claim(tenant=acme), any(roles(admin), principal={id})) required"},
+			},
+			wantCode: 403,
+		},
+		{
+			name: "test custom policy",
+			policies: []adapter.Policy{
+				adapter.NewPolicy("read only", func(ctx context.Context, p *adapter.Principal, r *gohttp.Request) (bool, error) {
+					return r.Method == "GET", nil
+				}),
+			},
+			want:     response{Code: 200, Data: "john"},
+			wantCode: 200,
+		},
+		{
+			name: "test custom policy error",
+			policies: []adapter.Policy{
+				adapter.NewPolicy("lookup", func(ctx context.Context, p *adapter.Principal, r *gohttp.Request) (bool, error) {
+					return false, fmt.Errorf("db down")
+				}),
+			},
+			want:     response{Code: 500, Errors: []string{"authz: db down"}},
+			wantCode: 500,
+		},
+		{
+			name:     "test not authenticated",
+			noAuth:   true,
+			policies: []adapter.Policy{adapter.HasRoles("admin")},
+			want:     response{Code: 401, Errors: []string{"authz: not authenticated"}},
+			wantCode: 401,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hf := http.AdaptHandlerFunc(
+				func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+					respond.WithJSON(w, r, adapter.PrincipalFromCtx(ctx).ID)
+				},
+				adapter.Require(c.policies...).Adapter,
+			)
+			if !c.noAuth {
+				hf = adapter.WithAPIKeyAuth(
+					credStore{"key": {PrincipalID: "john", Secret: []byte("key"), Claims: c.claims}},
+					func(context.Context, string, map[string]interface{}) error { return nil },
+				)(hf)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/{tenant}/orders/{id}", func(w gohttp.ResponseWriter, r *gohttp.Request) {
+				hf(r.Context(), w, r)
+			})
+
+			path := c.path
+			if path == "" {
+				path = "/acme/orders/john"
+			}
+
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("X-API-Key", "key")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			var resp response
+			json.NewDecoder(w.Body).Decode(&resp)
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.want, resp)
+		})
+	}
+}
+
+func TestRequire_RouteTable(t *testing.T) {
+	var s struct{ http.BaseService }
+
+	h := func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {}
+
+	s.RegisterHandler("POST", "/orders", h, adapter.RequireScopes("orders:write"))
+	s.RegisterHandler("GET", "/status", h)
+	s.RegisterHandler("DELETE", "/orders/{id}", h, adapter.Require(
+		adapter.HasRoles("admin", "ops"),
+		adapter.ClaimMatchesVar("tenant", "tenant"),
+	))
+	s.Adapt(adapter.RequireScopes("orders"))
+
+	eps := s.Endpoints()
+
+	assert.Equal(t, http.Meta{adapter.AuthzMetaKey: {"scopes(orders:write)", "scopes(orders)"}}, eps["/orders"].Meta)
+	assert.Equal(t, http.Meta{adapter.AuthzMetaKey: {"scopes(orders)"}}, eps["/status"].Meta)
+	assert.Equal(
+		t,
+		http.Meta{adapter.AuthzMetaKey: {"roles(admin|ops)", "claim(tenant={tenant})", "scopes(orders)"}},
+		eps["/orders/{id}"].Meta,
+	)
+}
@@ -0,0 +1,67 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// WithBasicAuth represents HTTP Basic authentication adapter
+// It verifies username and password sent in Authorization header against the
+// credential stored in provided store under the username, and if successful
+// calls callback (if not nil) to perform client side auth check.
+// Authenticated principal is stored to context (see PrincipalFromCtx).
+func WithBasicAuth(store CredentialStore, callback AuthCallbackFunc, opts ...CredentialOption) http.Adapter {
+	cfg := newCredCfg(opts)
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, cfg.realm)
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("no basic auth credentials found")),
+				)
+				return
+			}
+
+			cred, err := VerifyCredential(ctx, store, cfg.hash, user, []byte(pass))
+			if err != nil {
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusInternalServerError, fmt.Errorf("could not verify credentials: %v", err)),
+				)
+				return
+			}
+			if cred == nil {
+				w.Header().Set("WWW-Authenticate", challenge)
+				respond.WithJSON(
+					w, r,
+					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("invalid credentials")),
+				)
+				return
+			}
+
+			if callback != nil {
+				if err := callback(ctx, user, cred.Claims); err != nil {
+					respond.WithJSON(
+						w, r,
+						http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("unauthorized: %v", err)),
+					)
+					return
+				}
+			}
+
+			id := cred.PrincipalID
+			if id == "" {
+				id = user
+			}
+
+			h(withPrincipal(ctx, &Principal{ID: id, Scheme: "basic", Claims: cred.Claims}), w, r)
+		}
+	}
+}
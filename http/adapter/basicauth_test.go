@@ -0,0 +1,134 @@
+package adapter_test
+
+import (
+	"context"
+	"encoding/json"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestWithBasicAuth(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("partner-pass"), bcrypt.MinCost)
+
+	store := credStore{
+		"partner": {Secret: hash, Claims: map[string]interface{}{"tier": "gold"}},
+	}
+
+	cases := []struct {
+		name          string
+		user          string
+		pass          string
+		noAuth        bool
+		want          response
+		wantCode      int
+		wantChallenge string
+	}{
+		{
+			name:     "test valid credentials",
+			user:     "partner",
+			pass:     "partner-pass",
+			want:     response{Code: 200, Data: "partner"},
+			wantCode: 200,
+		},
+		{
+			name:          "test invalid password",
+			user:          "partner",
+			pass:          "wrong",
+			want:          response{Code: 401, Errors: []string{"invalid credentials"}},
+			wantCode:      401,
+			wantChallenge: `Basic realm="partners", charset="UTF-8"`,
+		},
+		{
+			name:          "test unknown user",
+			user:          "nobody",
+			pass:          "partner-pass",
+			want:          response{Code: 401, Errors: []string{"invalid credentials"}},
+			wantCode:      401,
+			wantChallenge: `Basic realm="partners", charset="UTF-8"`,
+		},
+		{
+			name:          "test no credentials",
+			noAuth:        true,
+			want:          response{Code: 401, Errors: []string{"no basic auth credentials found"}},
+			wantCode:      401,
+			wantChallenge: `Basic realm="partners", charset="UTF-8"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apt := adapter.WithBasicAuth(
+				store,
+				func(ctx context.Context, user string, claims map[string]interface{}) error {
+					assert.Equal(t, "gold", claims["tier"])
+					return nil
+				},
+				adapter.WithSecretHash(adapter.NewBcryptSecret(bcrypt.MinCost)),
+				adapter.WithBasicRealm("partners"),
+			)
+
+			hdlr := apt(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				respond.WithJSON(w, r, adapter.PrincipalFromCtx(ctx).ID)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if !c.noAuth {
+				req.SetBasicAuth(c.user, c.pass)
+			}
+
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, req)
+
+			resp := response{}
+			json.NewDecoder(w.Body).Decode(&resp)
+
+			assert.Equal(t, c.want, resp)
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.wantChallenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestVerifyCredential_UnknownID(t *testing.T) {
+	store := credStore{"partner": {Secret: []byte("stored")}}
+
+	var compared [][]byte
+	hash := dummyHash{
+		SecretHash: adapter.SecretHashFunc(func(stored, provided []byte) bool {
+			compared = append(compared, stored)
+			return string(stored) == string(provided)
+		}),
+		dummy: []byte("dummy"),
+	}
+
+	cred, err := adapter.VerifyCredential(context.Background(), store, hash, "nobody", []byte("dummy"))
+	assert.NoError(t, err)
+	assert.Nil(t, cred)
+
+	cred, err = adapter.VerifyCredential(context.Background(), store, hash, "partner", []byte("stored"))
+	assert.NoError(t, err)
+	assert.NotNil(t, cred)
+
+	assert.Equal(t, [][]byte{[]byte("dummy"), []byte("stored")}, compared)
+}
+
+func TestNewBcryptSecret_Dummy(t *testing.T) {
+	hash := adapter.NewBcryptSecret(bcrypt.MinCost).(adapter.DummySecretHash)
+
+	cost, err := bcrypt.Cost(hash.DummySecret())
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost)
+}
+
+type dummyHash struct {
+	adapter.SecretHash
+	dummy []byte
+}
+
+func (h dummyHash) DummySecret() []byte { return h.dummy }
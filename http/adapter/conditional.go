@@ -0,0 +1,154 @@
+package adapter
+
+import (
+	"context"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/tonto/kit/http"
+)
+
+// Matcher reports whether a request matches, and is used to
+// apply adapters conditionally (see When and Unless)
+type Matcher func(context.Context, *gohttp.Request) bool
+
+// When applies adapter only to requests matching m, eg:
+//
+//	adapter.When(adapter.Method("POST", "PUT"), adapter.WithIdempotency(store))
+func When(m Matcher, a http.Adapter) http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		adapted := a(h)
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			if m(c, r) {
+				adapted(c, w, r)
+				return
+			}
+			h(c, w, r)
+		}
+	}
+}
+
+// Unless skips adapter for requests matching m, eg:
+//
+//	adapter.Unless(adapter.PathPrefix("/healthz"), jwt)
+func Unless(m Matcher, a http.Adapter) http.Adapter {
+	return When(Not(m), a)
+}
+
+// Method matches requests with any of the methods
+func Method(methods ...string) Matcher {
+	return func(_ context.Context, r *gohttp.Request) bool {
+		for _, m := range methods {
+			if strings.EqualFold(m, r.Method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// safeMethod reports whether m is a safe (read-only) method
+func safeMethod(m string) bool {
+	switch m {
+	case gohttp.MethodGet, gohttp.MethodHead, gohttp.MethodOptions, gohttp.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// PathPrefix matches requests whose path starts with any of the prefixes
+func PathPrefix(prefixes ...string) Matcher {
+	return func(_ context.Context, r *gohttp.Request) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(r.URL.Path, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Path matches requests whose path equals any of the patterns.
+// Patterns ending with * match by prefix.
+func Path(patterns ...string) Matcher {
+	return func(_ context.Context, r *gohttp.Request) bool {
+		return matchPath(patterns, r.URL.Path)
+	}
+}
+
+// Route matches requests routed to any of the route templates
+// (eg. /orders/{id}), see http.RouteFromCtx
+func Route(paths ...string) Matcher {
+	return func(c context.Context, _ *gohttp.Request) bool {
+		rt := http.RouteFromCtx(c)
+		return rt != nil && containsString(paths, rt.Path)
+	}
+}
+
+// Service matches requests routed to any of the services
+// (by service type name, eg. OrderService)
+func Service(names ...string) Matcher {
+	return func(c context.Context, _ *gohttp.Request) bool {
+		rt := http.RouteFromCtx(c)
+		return rt != nil && containsString(names, rt.Service)
+	}
+}
+
+// RouteMeta matches requests routed to endpoints which declared meta key
+// (see http.WithMeta), with any of the values if provided
+func RouteMeta(key string, values ...string) Matcher {
+	return func(c context.Context, _ *gohttp.Request) bool {
+		rt := http.RouteFromCtx(c)
+		if rt == nil {
+			return false
+		}
+		declared, ok := rt.Meta[key]
+		if !ok || len(values) == 0 {
+			return ok
+		}
+		for _, v := range declared {
+			if containsString(values, v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Preflight matches CORS preflight requests
+func Preflight() Matcher {
+	return func(_ context.Context, r *gohttp.Request) bool {
+		return isPreflight(r)
+	}
+}
+
+// Not matches requests not matching m
+func Not(m Matcher) Matcher {
+	return func(c context.Context, r *gohttp.Request) bool {
+		return !m(c, r)
+	}
+}
+
+// Any matches requests matching any of the matchers
+func Any(matchers ...Matcher) Matcher {
+	return func(c context.Context, r *gohttp.Request) bool {
+		for _, m := range matchers {
+			if m(c, r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// All matches requests matching all of the matchers
+func All(matchers ...Matcher) Matcher {
+	return func(c context.Context, r *gohttp.Request) bool {
+		for _, m := range matchers {
+			if !m(c, r) {
+				return false
+			}
+		}
+		return true
+	}
+}
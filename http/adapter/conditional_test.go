@@ -0,0 +1,161 @@
+package adapter_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+)
+
+type OrderService struct {
+	http.BaseService
+}
+
+func (s *OrderService) Prefix() string { return "orders" }
+
+func TestConditional(t *testing.T) {
+	marker := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			w.Header().Set("X-Applied", "true")
+			h(c, w, r)
+		}
+	}
+
+	audited := http.WithMeta(http.Meta{"audit": {"orders"}}, func(h http.HandlerFunc) http.HandlerFunc {
+		return h
+	})
+
+	cases := []struct {
+		name        string
+		adapter     http.Adapter
+		method      string
+		path        string
+		wantApplied bool
+	}{
+		{
+			name:        "test unless path prefix",
+			adapter:     adapter.Unless(adapter.PathPrefix("/healthz"), marker),
+			path:        "/healthz/live",
+			wantApplied: false,
+		},
+		{
+			name:        "test unless path prefix not matching",
+			adapter:     adapter.Unless(adapter.PathPrefix("/healthz"), marker),
+			path:        "/orders/1",
+			wantApplied: true,
+		},
+		{
+			name:        "test when method",
+			adapter:     adapter.When(adapter.Method("post", "PUT"), marker),
+			method:      "POST",
+			path:        "/orders/1/cancel",
+			wantApplied: true,
+		},
+		{
+			name:        "test when method not matching",
+			adapter:     adapter.When(adapter.Method("POST", "PUT"), marker),
+			path:        "/orders/1",
+			wantApplied: false,
+		},
+		{
+			name:        "test path pattern",
+			adapter:     adapter.When(adapter.Path("/admin/*"), marker),
+			path:        "/admin/metrics",
+			wantApplied: true,
+		},
+		{
+			name:        "test route template",
+			adapter:     adapter.When(adapter.Route("/orders/{id}"), marker),
+			path:        "/orders/42",
+			wantApplied: true,
+		},
+		{
+			name:        "test service",
+			adapter:     adapter.When(adapter.Service("OrderService"), marker),
+			path:        "/orders/42",
+			wantApplied: true,
+		},
+		{
+			name:        "test service unmatched route",
+			adapter:     adapter.When(adapter.Service("OrderService"), marker),
+			path:        "/healthz/live",
+			wantApplied: false,
+		},
+		{
+			name:        "test route meta",
+			adapter:     adapter.When(adapter.RouteMeta("audit"), marker),
+			method:      "POST",
+			path:        "/orders/1/cancel",
+			wantApplied: true,
+		},
+		{
+			name:        "test route meta value",
+			adapter:     adapter.When(adapter.RouteMeta("audit", "payments"), marker),
+			method:      "POST",
+			path:        "/orders/1/cancel",
+			wantApplied: false,
+		},
+		{
+			name:        "test route meta not declared",
+			adapter:     adapter.When(adapter.RouteMeta("audit"), marker),
+			path:        "/orders/1",
+			wantApplied: false,
+		},
+		{
+			name:        "test unless preflight",
+			adapter:     adapter.Unless(adapter.Preflight(), marker),
+			method:      "OPTIONS",
+			path:        "/orders/1",
+			wantApplied: false,
+		},
+		{
+			name:        "test all",
+			adapter:     adapter.When(adapter.All(adapter.Method("POST"), adapter.PathPrefix("/orders")), marker),
+			method:      "POST",
+			path:        "/orders/1/cancel",
+			wantApplied: true,
+		},
+		{
+			name:        "test any",
+			adapter:     adapter.When(adapter.Any(adapter.Method("DELETE"), adapter.PathPrefix("/admin")), marker),
+			path:        "/orders/1",
+			wantApplied: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := http.NewServer(
+				http.WithLogger(log.New(ioutil.Discard, "", 0)),
+				http.WithAdapters(c.adapter),
+			)
+
+			svc := OrderService{}
+			noop := func(context.Context, gohttp.ResponseWriter, *gohttp.Request) {}
+			svc.RegisterHandler("GET", "/{id}", noop)
+			svc.RegisterHandler("POST", "/{id}/cancel", noop, audited)
+			srv.MustRegisterService(&svc)
+
+			mtd := "GET"
+			if c.method != "" {
+				mtd = c.method
+			}
+			req := httptest.NewRequest(mtd, c.path, nil)
+			if mtd == "OPTIONS" {
+				req.Header.Set("Origin", "https://foobar.com")
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+
+			srv.ServeHTTP(w, req)
+
+			assert.Equal(t, c.wantApplied, w.Header().Get("X-Applied") == "true")
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	gohttp "net/http"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/tonto/kit/http"
@@ -12,54 +14,42 @@ import (
 // CORSOption represents cors option
 type CORSOption func(*corsCfg)
 
-// WithCORS creates a new CORS adapter
+// WithCORS creates a new CORS adapter following the Fetch spec.
+// Request Origin header is matched against allowed origins, and if it
+// matches, it is echoed back in Access-Control-Allow-Origin (or * is sent
+// if all origins are allowed and credentials are not).
+// Preflight requests are answered with 204 and never reach the handler.
 func WithCORS(opts ...CORSOption) http.Adapter {
-	cfg := corsCfg{}
+	cfg := corsCfg{
+		methods: "GET, HEAD, POST, PUT, PATCH, DELETE",
+		headers: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+		maxAge:  "3600",
+	}
 	for _, o := range opts {
 		o(&cfg)
 	}
 	return func(h http.HandlerFunc) http.HandlerFunc {
 		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
-			if cfg.origins != nil {
-				var origins string
-				for _, o := range cfg.origins {
-					if o == "*" {
-						origins = "*"
-						break
-					}
-					if r.Host == o {
-						origins = o
-						break
-					}
-				}
-				if origins != "" {
-					w.Header().Add("Access-Control-Allow-Origin", origins)
-				}
+			origin := r.Header.Get("Origin")
+
+			if isPreflight(r) {
+				cfg.preflight(w, r, origin)
+				return
 			}
 
-			if r.Method == "OPTIONS" {
-				methods := cfg.methods
-				if methods == "" {
-					methods = "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH"
-				}
-				w.Header().Add("Access-Control-Allow-Methods", methods)
+			hdr := w.Header()
+			if cfg.varies() {
+				hdr.Add("Vary", "Origin")
+			}
 
-				headers := cfg.headers
-				if headers == "" {
-					headers = "Authorization, Accept, Accept-Language, Content-Language, Content-Type"
+			if origin != "" && cfg.allowed(origin) {
+				hdr.Set("Access-Control-Allow-Origin", cfg.allowOrigin(origin))
+				if cfg.credentials {
+					hdr.Set("Access-Control-Allow-Credentials", "true")
 				}
-				w.Header().Add("Access-Control-Allow-Headers", headers)
-
-				age := cfg.maxAge
-				if age == "" {
-					age = "3600"
+				if cfg.expose != "" {
+					hdr.Set("Access-Control-Expose-Headers", cfg.expose)
 				}
-				w.Header().Add("Access-Control-Max-Age", age)
-
-				w.Header().Add("Access-Control-Allow-Credentials", "true")
-
-				w.WriteHeader(200)
-				return
 			}
 
 			h(c, w, r)
@@ -68,36 +58,201 @@ func WithCORS(opts ...CORSOption) http.Adapter {
 }
 
 type corsCfg struct {
-	origins []string
-	methods string
-	headers string
-	maxAge  string
+	origins        []string
+	regexps        []*regexp.Regexp
+	allowAll       bool
+	methods        string
+	headers        string
+	expose         string
+	maxAge         string
+	credentials    bool
+	privateNetwork bool
+}
+
+func (cfg *corsCfg) preflight(w gohttp.ResponseWriter, r *gohttp.Request, origin string) {
+	hdr := w.Header()
+
+	if cfg.varies() {
+		hdr.Add("Vary", "Origin")
+	}
+	hdr.Add("Vary", "Access-Control-Request-Method")
+	hdr.Add("Vary", "Access-Control-Request-Headers")
+	if cfg.privateNetwork {
+		hdr.Add("Vary", "Access-Control-Request-Private-Network")
+	}
+
+	if !cfg.allowed(origin) {
+		w.WriteHeader(gohttp.StatusNoContent)
+		return
+	}
+
+	hdr.Set("Access-Control-Allow-Origin", cfg.allowOrigin(origin))
+	hdr.Set("Access-Control-Allow-Methods", cfg.methods)
+	hdr.Set("Access-Control-Allow-Headers", cfg.headers)
+	hdr.Set("Access-Control-Max-Age", cfg.maxAge)
+
+	if cfg.credentials {
+		hdr.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if cfg.privateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		hdr.Set("Access-Control-Allow-Private-Network", "true")
+	}
+
+	w.WriteHeader(gohttp.StatusNoContent)
+}
+
+func isPreflight(r *gohttp.Request) bool {
+	return r.Method == gohttp.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// varies reports whether response depends on request origin,
+// which is the case unless * is sent to every origin
+func (cfg *corsCfg) varies() bool {
+	return !cfg.allowAll || cfg.credentials
+}
+
+// allowOrigin returns Access-Control-Allow-Origin value for allowed origin.
+// * can not be used with credentialed requests, so origin is echoed back then.
+func (cfg *corsCfg) allowOrigin(origin string) string {
+	if cfg.allowAll && !cfg.credentials {
+		return "*"
+	}
+	return origin
 }
 
-// WithCORSAllowOrigins sets allowed origins
+func (cfg *corsCfg) allowed(origin string) bool {
+	if cfg.allowAll {
+		return true
+	}
+
+	for _, re := range cfg.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, o := range cfg.origins {
+		if matchOrigin(o, u) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOrigin matches origin against pattern, which can either be a
+// full origin (https://foo.com) or a host only (foo.com) matching any
+// scheme. Host can start with *. to match any of its subdomains.
+// Full origins without a port match the default port of their scheme,
+// while host only patterns without a port match any port.
+func matchOrigin(pattern string, origin *url.URL) bool {
+	scheme, host := "", pattern
+	if i := strings.Index(pattern, "://"); i != -1 {
+		scheme, host = pattern[:i], pattern[i+3:]
+		if scheme != origin.Scheme {
+			return false
+		}
+	}
+
+	p := url.URL{Host: host}
+	hostname, port := p.Hostname(), p.Port()
+	if port == "" && scheme != "" {
+		port = defaultPort(scheme)
+	}
+	if port != "" && port != originPort(origin) {
+		return false
+	}
+
+	if strings.HasPrefix(hostname, "*.") {
+		return strings.HasSuffix(origin.Hostname(), hostname[1:])
+	}
+
+	return hostname == origin.Hostname()
+}
+
+// originPort returns origin port, defaulting to the one of its scheme
+func originPort(origin *url.URL) string {
+	if p := origin.Port(); p != "" {
+		return p
+	}
+	return defaultPort(origin.Scheme)
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// WithCORSAllowOrigins sets allowed origins. Origins can be either
+// full origins (https://foo.com) or hosts (foo.com) matching any scheme
+// and port (unless one is given, eg. foo.com:8443), while *.foo.com matches
+// any subdomain of foo.com and * allows all origins.
 func WithCORSAllowOrigins(origins ...string) CORSOption {
 	return func(cfg *corsCfg) {
-		cfg.origins = origins
+		for _, o := range origins {
+			if o == "*" {
+				cfg.allowAll = true
+				continue
+			}
+			cfg.origins = append(cfg.origins, strings.ToLower(strings.TrimRight(o, "/")))
+		}
+	}
+}
+
+// WithCORSAllowOriginRegexps allows origins matching any of provided regexps
+func WithCORSAllowOriginRegexps(res ...*regexp.Regexp) CORSOption {
+	return func(cfg *corsCfg) {
+		cfg.regexps = append(cfg.regexps, res...)
 	}
 }
 
 // WithCORSAllowMethods sets allowed methods
 func WithCORSAllowMethods(methods ...string) CORSOption {
 	return func(cfg *corsCfg) {
-		for _, mtd := range methods {
-			cfg.methods += strings.ToUpper(mtd) + ", "
-		}
-		cfg.methods = strings.TrimRight(cfg.methods, ", ")
+		cfg.methods = strings.ToUpper(strings.Join(methods, ", "))
 	}
 }
 
 // WithCORSAllowHeaders sets allowed headers
 func WithCORSAllowHeaders(headers ...string) CORSOption {
 	return func(cfg *corsCfg) {
-		for _, h := range headers {
-			cfg.headers += h + ", "
-		}
-		cfg.headers = strings.TrimRight(cfg.headers, ", ")
+		cfg.headers = strings.Join(headers, ", ")
+	}
+}
+
+// WithCORSExposeHeaders sets response headers exposed to the client
+func WithCORSExposeHeaders(headers ...string) CORSOption {
+	return func(cfg *corsCfg) {
+		cfg.expose = strings.Join(headers, ", ")
+	}
+}
+
+// WithCORSAllowCredentials allows credentialed requests (cookies, auth headers).
+// Origin is echoed back instead of * when set together with allowing all origins.
+func WithCORSAllowCredentials() CORSOption {
+	return func(cfg *corsCfg) {
+		cfg.credentials = true
+	}
+}
+
+// WithCORSPrivateNetwork allows private network access preflight
+// requests (Access-Control-Request-Private-Network)
+func WithCORSPrivateNetwork() CORSOption {
+	return func(cfg *corsCfg) {
+		cfg.privateNetwork = true
 	}
 }
 
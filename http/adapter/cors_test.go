@@ -4,6 +4,7 @@ import (
 	"context"
 	gohttp "net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,125 +13,318 @@ import (
 )
 
 func TestWithCORS(t *testing.T) {
+	defaultMethods := "GET, HEAD, POST, PUT, PATCH, DELETE"
+	defaultHeaders := "Authorization, Accept, Accept-Language, Content-Language, Content-Type"
+
 	cases := []struct {
 		name        string
-		origins     []string
-		headers     []string
-		methods     []string
+		opts        []adapter.CORSOption
 		method      string
-		host        string
-		maxAge      int
-		wantOrigins string
-		wantMethods string
-		wantHeaders string
-		wantMaxAge  string
+		origin      string
+		reqHeaders  map[string]string
+		wantCode    int
+		wantHandled bool
+		want        map[string]string
+		wantVary    []string
 	}{
+		{
+			name:     "test asterisk origin preflight",
+			opts:     []adapter.CORSOption{adapter.WithCORSAllowOrigins("*")},
+			method:   "OPTIONS",
+			origin:   "https://foobar.com",
+			wantCode: 204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Methods":     defaultMethods,
+				"Access-Control-Allow-Headers":     defaultHeaders,
+				"Access-Control-Max-Age":           "3600",
+				"Access-Control-Allow-Credentials": "",
+			},
+			wantVary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
 		{
 			name:        "test asterisk origin",
-			wantMaxAge:  "3600",
-			origins:     []string{"*"},
-			wantOrigins: "*",
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*")},
+			origin:      "https://foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		{
+			name:        "test asterisk origin with credentials",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*"), adapter.WithCORSAllowCredentials()},
+			origin:      "https://foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://foobar.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+			wantVary: []string{"Origin"},
+		},
+		{
+			name:        "test origin matched against origin header not host",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com", "https://foobaz.org")},
+			origin:      "https://foobaz.org",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://foobaz.org"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test host only origin",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("foobar.com")},
+			origin:      "http://foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "http://foobar.com"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test scheme mismatch",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com")},
+			origin:      "http://foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test wildcard subdomain",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://*.foobar.com")},
+			origin:      "https://api.eu.foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://api.eu.foobar.com"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test wildcard subdomain does not match suffix",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://*.foobar.com")},
+			origin:      "https://evilfoobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test wildcard subdomain with port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*.foobar.com")},
+			origin:      "https://app.foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://app.foobar.com:8443"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test host only origin with port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("foobar.com")},
+			origin:      "http://foobar.com:3000",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "http://foobar.com:3000"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test full origin port mismatch",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com")},
+			origin:      "https://foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test wildcard subdomain with pattern port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*.foobar.com:8443")},
+			origin:      "https://app.foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://app.foobar.com:8443"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test wildcard subdomain pattern port mismatch",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*.foobar.com:8443")},
+			origin:      "https://app.foobar.com:9999",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test full origin wildcard with port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://*.foobar.com:8443")},
+			origin:      "https://app.foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://app.foobar.com:8443"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test full origin wildcard non default port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://*.foobar.com")},
+			origin:      "https://app.foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test full origin wildcard explicit default port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://*.foobar.com")},
+			origin:      "https://app.foobar.com:443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://app.foobar.com:443"},
+			wantVary:    []string{"Origin"},
+		},
+		{
+			name:        "test full origin with port",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com:8443")},
+			origin:      "https://foobar.com:8443",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://foobar.com:8443"},
+			wantVary:    []string{"Origin"},
 		},
 		{
-			name:        "test origin",
-			wantMaxAge:  "3600",
-			origins:     []string{"foobar.com"},
-			wantOrigins: "foobar.com",
-			host:        "foobar.com",
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			name:        "test regexp origin",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOriginRegexps(regexp.MustCompile(`^https://pr-\d+\.foobar\.dev$`))},
+			origin:      "https://pr-42.foobar.dev",
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "https://pr-42.foobar.dev"},
+			wantVary:    []string{"Origin"},
 		},
 		{
-			name:        "test origins",
-			wantMaxAge:  "3600",
-			origins:     []string{"foobar.com", "foobaz.org"},
-			wantOrigins: "foobar.com",
-			host:        "foobar.com",
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			name:        "test no origin",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com")},
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:    []string{"Origin"},
 		},
 		{
-			name:        "test asterisk origin override",
-			wantMaxAge:  "3600",
-			origins:     []string{"*", "foobaz.org"},
-			wantOrigins: "*",
-			host:        "foobar.com",
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			name:        "test expose headers",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*"), adapter.WithCORSExposeHeaders("X-Total", "ETag")},
+			origin:      "https://foobar.com",
+			wantCode:    200,
+			wantHandled: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "X-Total, ETag",
+			},
 		},
 		{
-			name:        "test methods",
-			wantMaxAge:  "3600",
-			methods:     []string{"GET", "post", "PUT"},
-			wantMethods: "GET, POST, PUT",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			name: "test preflight options",
+			opts: []adapter.CORSOption{
+				adapter.WithCORSAllowOrigins("https://foobar.com"),
+				adapter.WithCORSAllowMethods("GET", "post", "PUT"),
+				adapter.WithCORSAllowHeaders("Content-Type", "X-Request-Id"),
+				adapter.WithCORSMaxAge(86400),
+				adapter.WithCORSAllowCredentials(),
+			},
+			method:   "OPTIONS",
+			origin:   "https://foobar.com",
+			wantCode: 204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://foobar.com",
+				"Access-Control-Allow-Methods":     "GET, POST, PUT",
+				"Access-Control-Allow-Headers":     "Content-Type, X-Request-Id",
+				"Access-Control-Max-Age":           "86400",
+				"Access-Control-Allow-Credentials": "true",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
 		},
 		{
-			name:        "test headers",
-			wantMaxAge:  "3600",
-			headers:     []string{"Connection", "Content-Length"},
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Connection, Content-Length",
+			name:     "test preflight disallowed origin",
+			opts:     []adapter.CORSOption{adapter.WithCORSAllowOrigins("https://foobar.com")},
+			method:   "OPTIONS",
+			origin:   "https://foobaz.org",
+			wantCode: 204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
 		},
 		{
-			name:        "test max age",
-			maxAge:      86400,
-			wantMaxAge:  "86400",
-			wantMethods: "GET, POST, PUT, DELETE, CONNECT, OPTIONS, TRACE, PATCH",
-			wantHeaders: "Authorization, Accept, Accept-Language, Content-Language, Content-Type",
+			name:       "test private network preflight",
+			opts:       []adapter.CORSOption{adapter.WithCORSAllowOrigins("*"), adapter.WithCORSPrivateNetwork()},
+			method:     "OPTIONS",
+			origin:     "https://foobar.com",
+			reqHeaders: map[string]string{"Access-Control-Request-Private-Network": "true"},
+			wantCode:   204,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":          "*",
+				"Access-Control-Allow-Private-Network": "true",
+			},
+			wantVary: []string{
+				"Access-Control-Request-Method",
+				"Access-Control-Request-Headers",
+				"Access-Control-Request-Private-Network",
+			},
 		},
 		{
-			name:        "test non options origin",
-			method:      "GET",
-			origins:     []string{"*"},
-			wantOrigins: "*",
+			name:       "test private network not enabled",
+			opts:       []adapter.CORSOption{adapter.WithCORSAllowOrigins("*")},
+			method:     "OPTIONS",
+			origin:     "https://foobar.com",
+			reqHeaders: map[string]string{"Access-Control-Request-Private-Network": "true"},
+			wantCode:   204,
+			want:       map[string]string{"Access-Control-Allow-Private-Network": ""},
+			wantVary:   []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:        "test options without request method is not preflight",
+			opts:        []adapter.CORSOption{adapter.WithCORSAllowOrigins("*")},
+			method:      "OPTIONS",
+			origin:      "https://foobar.com",
+			reqHeaders:  map[string]string{"Access-Control-Request-Method": ""},
+			wantCode:    200,
+			wantHandled: true,
+			want:        map[string]string{"Access-Control-Allow-Origin": "*"},
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			var opts []adapter.CORSOption
-
-			opts = append(opts, adapter.WithCORSAllowOrigins(c.origins...))
+			handled := false
 
-			if c.methods != nil {
-				opts = append(opts, adapter.WithCORSAllowMethods(c.methods...))
-			}
-
-			if c.headers != nil {
-				opts = append(opts, adapter.WithCORSAllowHeaders(c.headers...))
-			}
-
-			if c.maxAge != 0 {
-				opts = append(opts, adapter.WithCORSMaxAge(c.maxAge))
-			}
-
-			apt := adapter.WithCORS(opts...)
-
-			hdlr := apt(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			hdlr := adapter.WithCORS(c.opts...)(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				handled = true
 				respond.WithJSON(w, r, "response")
 			})
 
-			mtd := "OPTIONS"
+			mtd := "GET"
 			if c.method != "" {
 				mtd = c.method
 			}
-			req, _ := gohttp.NewRequest(mtd, "/", nil)
-			req.Host = c.host
+			req := httptest.NewRequest(mtd, "/", nil)
+			req.Host = "api.foobar.com"
+			if c.origin != "" {
+				req.Header.Set("Origin", c.origin)
+			}
+			if mtd == "OPTIONS" {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			for k, v := range c.reqHeaders {
+				req.Header.Set(k, v)
+			}
 
 			w := httptest.NewRecorder()
 			hdlr(context.Background(), w, req)
 
-			// resp := response{}
-			// json.NewDecoder(w.Body).Decode(&resp)
-
-			// assert.Equal(t, "response", resp.Data)
-			assert.Equal(t, c.wantOrigins, w.Header().Get("Access-Control-Allow-Origin"))
-			assert.Equal(t, c.wantMethods, w.Header().Get("Access-Control-Allow-Methods"))
-			assert.Equal(t, c.wantHeaders, w.Header().Get("Access-Control-Allow-Headers"))
-			assert.Equal(t, c.wantMaxAge, w.Header().Get("Access-Control-Max-Age"))
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.wantHandled, handled)
+			for k, v := range c.want {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+			assert.Equal(t, c.wantVary, w.Header()["Vary"])
 		})
 	}
 }
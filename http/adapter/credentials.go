@@ -0,0 +1,224 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+
+	"github.com/tonto/kit/http"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PrincipalKey is used to store authenticated principal to context
+const PrincipalKey = "tonto_http_principal_key"
+
+// Principal represents an authenticated client
+type Principal struct {
+	// ID uniquely identifies the principal (username, key owner, token subject...)
+	ID string
+
+	// Scheme is the name of the scheme principal was authenticated with
+	Scheme string
+
+	// Claims holds any additional data known about the principal
+	Claims map[string]interface{}
+}
+
+// PrincipalFromCtx returns authenticated principal associated with context
+// or nil if request was not authenticated
+func PrincipalFromCtx(c context.Context) *Principal {
+	if p, ok := c.Value(http.ContextKey(PrincipalKey)).(*Principal); ok {
+		return p
+	}
+	return nil
+}
+
+func withPrincipal(c context.Context, p *Principal) context.Context {
+	return context.WithValue(c, http.ContextKey(PrincipalKey), p)
+}
+
+// Credential represents stored client credential
+type Credential struct {
+	// PrincipalID is the id of the principal owning the credential
+	PrincipalID string
+
+	// Secret holds either plain or hashed secret depending on
+	// SecretHash adapter is configured with
+	Secret []byte
+
+	// Claims are passed to AuthCallbackFunc and stored with principal
+	Claims map[string]interface{}
+}
+
+// CredentialStore is used by credential based adapters (api key, basic auth)
+// to look up stored credentials by identity (api key id or basic auth username).
+// Implementors should return nil Credential if none is found.
+type CredentialStore interface {
+	Credential(ctx context.Context, id string) (*Credential, error)
+}
+
+// CredentialStoreFunc is a func implementation of CredentialStore
+type CredentialStoreFunc func(context.Context, string) (*Credential, error)
+
+// Credential calls f(ctx, id)
+func (f CredentialStoreFunc) Credential(ctx context.Context, id string) (*Credential, error) {
+	return f(ctx, id)
+}
+
+// SecretHash compares stored (possibly hashed) secret with
+// the one provided by the client. Implementations must
+// perform the comparison in constant time.
+type SecretHash interface {
+	Compare(stored, provided []byte) bool
+}
+
+// DummySecretHash is optionally implemented by SecretHash, returning
+// a stored secret of its kind which secrets provided for unknown ids
+// are compared against, so that they take as long to check as known ones
+type DummySecretHash interface {
+	SecretHash
+	DummySecret() []byte
+}
+
+// SecretHashFunc is a func implementation of SecretHash
+type SecretHashFunc func(stored, provided []byte) bool
+
+// Compare calls f(stored, provided)
+func (f SecretHashFunc) Compare(stored, provided []byte) bool { return f(stored, provided) }
+
+var (
+	// PlainSecret compares plain text secrets
+	PlainSecret SecretHash = SecretHashFunc(func(stored, provided []byte) bool {
+		return subtle.ConstantTimeCompare(stored, provided) == 1
+	})
+
+	// SHA256Secret compares hex encoded sha256 hash of the secret
+	SHA256Secret SecretHash = secretHash{
+		compare: func(stored, provided []byte) bool {
+			sum := sha256.Sum256(provided)
+			return subtle.ConstantTimeCompare(stored, []byte(hex.EncodeToString(sum[:]))) == 1
+		},
+		dummy: func() []byte { return make([]byte, hex.EncodedLen(sha256.Size)) },
+	}
+
+	// BcryptSecret compares bcrypt hash of the secret, hashed with default cost
+	BcryptSecret = NewBcryptSecret(bcrypt.DefaultCost)
+)
+
+// NewBcryptSecret creates SecretHash comparing bcrypt hash of the secret.
+// Cost should match the one stored secrets are hashed with, as unknown
+// ids are checked against a dummy hash of that cost.
+func NewBcryptSecret(cost int) SecretHash {
+	var (
+		once  sync.Once
+		dummy []byte
+	)
+	return secretHash{
+		compare: func(stored, provided []byte) bool {
+			return bcrypt.CompareHashAndPassword(stored, provided) == nil
+		},
+		dummy: func() []byte {
+			once.Do(func() {
+				dummy, _ = bcrypt.GenerateFromPassword([]byte("dummy secret"), cost)
+			})
+			return dummy
+		},
+	}
+}
+
+type secretHash struct {
+	compare func(stored, provided []byte) bool
+	dummy   func() []byte
+}
+
+func (h secretHash) Compare(stored, provided []byte) bool { return h.compare(stored, provided) }
+
+func (h secretHash) DummySecret() []byte { return h.dummy() }
+
+// VerifyCredential looks up credential by id and checks provided secret
+// against it, returning nil Credential if either is not valid.
+// Secrets provided for unknown ids are compared against hash's
+// dummy secret (see DummySecretHash) in order to make
+// them harder to tell apart by response timing.
+func VerifyCredential(ctx context.Context, store CredentialStore, hash SecretHash, id string, secret []byte) (*Credential, error) {
+	cred, err := store.Credential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := []byte{}
+	if cred != nil {
+		stored = cred.Secret
+	} else if h, ok := hash.(DummySecretHash); ok {
+		stored = h.DummySecret()
+	}
+
+	if !hash.Compare(stored, secret) || cred == nil {
+		return nil, nil
+	}
+
+	return cred, nil
+}
+
+// CredentialOption represents credential based adapter option
+type CredentialOption func(*credCfg)
+
+type credCfg struct {
+	hash   SecretHash
+	realm  string
+	header string
+	query  string
+	keyID  func(string) string
+}
+
+func newCredCfg(opts []CredentialOption) *credCfg {
+	cfg := credCfg{
+		hash:   PlainSecret,
+		realm:  "restricted",
+		header: "X-API-Key",
+		keyID:  func(key string) string { return key },
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return &cfg
+}
+
+// WithSecretHash sets the hash used for stored secrets (PlainSecret by default)
+func WithSecretHash(h SecretHash) CredentialOption {
+	return func(cfg *credCfg) {
+		cfg.hash = h
+	}
+}
+
+// WithAPIKeyHeader sets the header api key is read from (X-API-Key by default)
+func WithAPIKeyHeader(name string) CredentialOption {
+	return func(cfg *credCfg) {
+		cfg.header = name
+	}
+}
+
+// WithAPIKeyQuery enables reading api key from given query parameter
+// if it was not found in a header
+func WithAPIKeyQuery(param string) CredentialOption {
+	return func(cfg *credCfg) {
+		cfg.query = param
+	}
+}
+
+// WithAPIKeyID sets a func which derives the id used for CredentialStore
+// lookup from the api key (eg. a key prefix). Whole key is used by default.
+func WithAPIKeyID(f func(key string) string) CredentialOption {
+	return func(cfg *credCfg) {
+		cfg.keyID = f
+	}
+}
+
+// WithBasicRealm sets basic auth realm sent with WWW-Authenticate header
+func WithBasicRealm(realm string) CredentialOption {
+	return func(cfg *credCfg) {
+		cfg.realm = realm
+	}
+}
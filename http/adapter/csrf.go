@@ -0,0 +1,272 @@
+package adapter
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	gohttp "net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// CSRFTokenKey is used to store csrf token to context
+const CSRFTokenKey = "tonto_http_csrf_token_key"
+
+// CSRFStore represents csrf token storage. Token should return
+// currently stored token for the request or empty string if
+// there is none, Save is used to persist a newly generated one.
+//
+// Default store keeps the token in a cookie (double-submit-cookie pattern),
+// provide a session backed store with WithCSRFStore to use the
+// synchronizer token pattern instead.
+type CSRFStore interface {
+	Token(*gohttp.Request) (string, error)
+	Save(gohttp.ResponseWriter, *gohttp.Request, string) error
+}
+
+// CSRFOption represents csrf option
+type CSRFOption func(*csrfCfg)
+
+// WithCSRF creates a new CSRF protection adapter
+// Safe requests (GET, HEAD, OPTIONS, TRACE) are let through and provided
+// with a token (stored in context, see CSRFTokenFromCtx), while unsafe requests need
+// to pass Origin/Referer check and carry a token matching the stored one
+// either in a header or a form field.
+func WithCSRF(opts ...CSRFOption) http.Adapter {
+	cfg := csrfCfg{
+		header:     "X-CSRF-Token",
+		field:      "csrf_token",
+		cookie:     "_csrf",
+		cookiePath: "/",
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = &csrfCookieStore{cfg: &cfg}
+	}
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			if cfg.exempt(r) {
+				h(c, w, r)
+				return
+			}
+
+			token, err := cfg.store.Token(r)
+			if err != nil {
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusForbidden, fmt.Errorf("csrf: could not get token: %v", err)))
+				return
+			}
+
+			if !safeMethod(r.Method) {
+				if err := cfg.checkOrigin(r); err != nil {
+					respond.WithJSON(w, r, http.NewError(gohttp.StatusForbidden, err))
+					return
+				}
+
+				if token == "" || !csrfTokensEqual(token, cfg.requestToken(r)) {
+					respond.WithJSON(w, r, http.NewError(gohttp.StatusForbidden, fmt.Errorf("csrf: invalid token")))
+					return
+				}
+			}
+
+			if token == "" {
+				token, err = newCSRFToken()
+				if err == nil {
+					err = cfg.store.Save(w, r, token)
+				}
+				if err != nil {
+					respond.WithJSON(w, r, http.NewError(gohttp.StatusForbidden, fmt.Errorf("csrf: could not save token: %v", err)))
+					return
+				}
+			}
+
+			h(context.WithValue(c, http.ContextKey(CSRFTokenKey), token), w, r)
+		}
+	}
+}
+
+// CSRFTokenFromCtx returns csrf token associated with context
+// so it can be embedded into forms or passed to clients
+func CSRFTokenFromCtx(c context.Context) string {
+	if t, ok := c.Value(http.ContextKey(CSRFTokenKey)).(string); ok {
+		return t
+	}
+	return ""
+}
+
+type csrfCfg struct {
+	store        CSRFStore
+	header       string
+	field        string
+	cookie       string
+	cookiePath   string
+	cookieDomain string
+	insecure     bool
+	origins      []string
+	exemptPaths  []string
+	skip         func(*gohttp.Request) bool
+}
+
+func (cfg *csrfCfg) exempt(r *gohttp.Request) bool {
+	if matchPath(cfg.exemptPaths, r.URL.Path) {
+		return true
+	}
+	return cfg.skip != nil && cfg.skip(r)
+}
+
+func (cfg *csrfCfg) checkOrigin(r *gohttp.Request) error {
+	src := r.Header.Get("Origin")
+	if src == "" {
+		src = r.Header.Get("Referer")
+	}
+	if src == "" {
+		// Neither is sent by some older clients, in which
+		// case we rely on the token check alone
+		return nil
+	}
+
+	u, err := url.Parse(src)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("csrf: invalid origin")
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	origin := u.Scheme + "://" + u.Host
+	for _, o := range cfg.origins {
+		if strings.EqualFold(o, origin) || strings.EqualFold(o, u.Host) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("csrf: origin not allowed")
+}
+
+func (cfg *csrfCfg) requestToken(r *gohttp.Request) string {
+	if t := r.Header.Get(cfg.header); t != "" {
+		return t
+	}
+	if cfg.field == "" {
+		return ""
+	}
+	ct := r.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, "application/x-www-form-urlencoded") || strings.HasPrefix(ct, "multipart/form-data") {
+		return r.PostFormValue(cfg.field)
+	}
+	return ""
+}
+
+type csrfCookieStore struct {
+	cfg *csrfCfg
+}
+
+func (s *csrfCookieStore) Token(r *gohttp.Request) (string, error) {
+	c, err := r.Cookie(s.cfg.cookie)
+	if err == gohttp.ErrNoCookie {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.Value, nil
+}
+
+func (s *csrfCookieStore) Save(w gohttp.ResponseWriter, r *gohttp.Request, token string) error {
+	gohttp.SetCookie(w, &gohttp.Cookie{
+		Name:     s.cfg.cookie,
+		Value:    token,
+		Path:     s.cfg.cookiePath,
+		Domain:   s.cfg.cookieDomain,
+		Secure:   !s.cfg.insecure,
+		SameSite: gohttp.SameSiteLaxMode,
+		// Cookie must be readable by client side scripts
+		// so they can submit it back in a header
+		HttpOnly: false,
+	})
+	return nil
+}
+
+func csrfTokensEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// WithCSRFStore sets csrf token store, thus switching from default
+// double-submit-cookie to synchronizer token pattern
+func WithCSRFStore(s CSRFStore) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.store = s
+	}
+}
+
+// WithCSRFHeader sets the name of the request header carrying
+// the token (X-CSRF-Token by default)
+func WithCSRFHeader(name string) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.header = name
+	}
+}
+
+// WithCSRFFormField sets the name of the form field carrying
+// the token (csrf_token by default), empty name disables form lookup
+func WithCSRFFormField(name string) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.field = name
+	}
+}
+
+// WithCSRFCookie sets double-submit cookie name, path and domain
+func WithCSRFCookie(name, path, domain string) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.cookie = name
+		cfg.cookiePath = path
+		cfg.cookieDomain = domain
+	}
+}
+
+// WithCSRFInsecureCookie disables Secure flag on double-submit
+// cookie (use only for local development over plain http)
+func WithCSRFInsecureCookie() CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.insecure = true
+	}
+}
+
+// WithCSRFTrustedOrigins sets additional origins (eg. https://admin.example.com or
+// admin.example.com) that unsafe requests are accepted from.
+// Requests coming from the same host are always accepted.
+func WithCSRFTrustedOrigins(origins ...string) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.origins = append(cfg.origins, origins...)
+	}
+}
+
+// WithCSRFExemptPaths sets request paths that opt out of csrf protection.
+// Path ending with * is treated as a prefix.
+func WithCSRFExemptPaths(paths ...string) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.exemptPaths = append(cfg.exemptPaths, paths...)
+	}
+}
+
+// WithCSRFSkipper sets a func deciding whether a request opts out of csrf protection
+func WithCSRFSkipper(f func(*gohttp.Request) bool) CSRFOption {
+	return func(cfg *csrfCfg) {
+		cfg.skip = f
+	}
+}
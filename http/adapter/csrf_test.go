@@ -0,0 +1,207 @@
+package adapter_test
+
+import (
+	"context"
+	"encoding/json"
+	gohttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+)
+
+func TestWithCSRF(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []adapter.CSRFOption
+		method   string
+		path     string
+		cookie   string
+		header   string
+		form     string
+		origin   string
+		referer  string
+		want     response
+		wantCode int
+	}{
+		{
+			name:     "test safe method passes",
+			method:   "GET",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test post no token",
+			method:   "POST",
+			want:     response{Code: 403, Errors: []string{"csrf: invalid token"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test post header token",
+			method:   "POST",
+			cookie:   "secret-token",
+			header:   "secret-token",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test post form token",
+			method:   "POST",
+			cookie:   "secret-token",
+			form:     "secret-token",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test post token mismatch",
+			method:   "POST",
+			cookie:   "secret-token",
+			header:   "other-token",
+			want:     response{Code: 403, Errors: []string{"csrf: invalid token"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test same origin",
+			method:   "DELETE",
+			cookie:   "secret-token",
+			header:   "secret-token",
+			origin:   "https://example.com",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test cross origin",
+			method:   "PUT",
+			cookie:   "secret-token",
+			header:   "secret-token",
+			origin:   "https://evil.com",
+			want:     response{Code: 403, Errors: []string{"csrf: origin not allowed"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test cross origin referer",
+			method:   "PUT",
+			cookie:   "secret-token",
+			header:   "secret-token",
+			referer:  "https://evil.com/some/page",
+			want:     response{Code: 403, Errors: []string{"csrf: origin not allowed"}},
+			wantCode: 403,
+		},
+		{
+			name:     "test trusted origin",
+			opts:     []adapter.CSRFOption{adapter.WithCSRFTrustedOrigins("https://admin.example.org")},
+			method:   "PATCH",
+			cookie:   "secret-token",
+			header:   "secret-token",
+			origin:   "https://admin.example.org",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test exempt path",
+			opts:     []adapter.CSRFOption{adapter.WithCSRFExemptPaths("/webhooks/*")},
+			method:   "POST",
+			path:     "/webhooks/stripe",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test synchronizer store",
+			opts:     []adapter.CSRFOption{adapter.WithCSRFStore(&sessionStore{token: "session-token"})},
+			method:   "POST",
+			cookie:   "session-token",
+			header:   "session-token",
+			want:     response{Code: 200, Data: "ok"},
+			wantCode: 200,
+		},
+		{
+			name:     "test synchronizer store ignores cookie",
+			opts:     []adapter.CSRFOption{adapter.WithCSRFStore(&sessionStore{token: "session-token"})},
+			method:   "POST",
+			cookie:   "forged-token",
+			header:   "forged-token",
+			want:     response{Code: 403, Errors: []string{"csrf: invalid token"}},
+			wantCode: 403,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apt := adapter.WithCSRF(c.opts...)
+
+			hdlr := apt(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				if c.path == "" {
+					assert.NotEmpty(t, adapter.CSRFTokenFromCtx(ctx))
+				}
+				respond.WithJSON(w, r, "ok")
+			})
+
+			path := "/"
+			if c.path != "" {
+				path = c.path
+			}
+
+			req := httptest.NewRequest(c.method, "https://example.com"+path, nil)
+			if c.form != "" {
+				req = httptest.NewRequest(
+					c.method,
+					"https://example.com"+path,
+					strings.NewReader(url.Values{"csrf_token": {c.form}}.Encode()),
+				)
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if c.cookie != "" {
+				req.AddCookie(&gohttp.Cookie{Name: "_csrf", Value: c.cookie})
+			}
+			if c.header != "" {
+				req.Header.Set("X-CSRF-Token", c.header)
+			}
+			if c.origin != "" {
+				req.Header.Set("Origin", c.origin)
+			}
+			if c.referer != "" {
+				req.Header.Set("Referer", c.referer)
+			}
+
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, req)
+
+			resp := response{}
+			json.NewDecoder(w.Body).Decode(&resp)
+
+			assert.Equal(t, c.want, resp)
+			assert.Equal(t, c.wantCode, w.Code)
+		})
+	}
+}
+
+func TestWithCSRF_IssuesCookie(t *testing.T) {
+	var token string
+	hdlr := adapter.WithCSRF()(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		token = adapter.CSRFTokenFromCtx(ctx)
+	})
+
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "_csrf", cookies[0].Name)
+	assert.Equal(t, token, cookies[0].Value)
+	assert.True(t, cookies[0].Secure)
+}
+
+type sessionStore struct {
+	token string
+}
+
+func (s *sessionStore) Token(*gohttp.Request) (string, error) { return s.token, nil }
+
+func (s *sessionStore) Save(w gohttp.ResponseWriter, r *gohttp.Request, token string) error {
+	s.token = token
+	return nil
+}
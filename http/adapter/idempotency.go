@@ -0,0 +1,355 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/tx"
+)
+
+const (
+	// IdempotencyKeyHeader is the header idempotency key is read from
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses replayed from a stored record
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// ErrIdempotencyLockLost is returned by IdempotencyStore when completing
+// or unlocking a record whose lock has been taken over by another request
+var ErrIdempotencyLockLost = errors.New("idempotency: lock lost")
+
+// IdempotencyRecord represents stored request identified by idempotency key
+type IdempotencyRecord struct {
+	Key string
+
+	// Fingerprint is a hash of request method, uri and body
+	Fingerprint string
+
+	// Status, Header and Body hold stored response (Header
+	// holding the ones set by the handler). Status is 0 while
+	// the request is in progress.
+	Status int
+	Header gohttp.Header
+	Body   []byte
+
+	// LockedUntil is the time after which in progress record can be taken
+	// over (eg. if the process handling it crashed). It also identifies
+	// the lock, so that only the request holding it can complete the record.
+	LockedUntil time.Time
+
+	ExpiresAt time.Time
+}
+
+// IdempotencyStore represents idempotency record storage.
+//
+// Stores also implementing tx.Transactional (eg. SQLIdempotencyStore) have
+// the handler run in a transaction, which Complete is called in as well.
+// Business changes made in the same transaction (eg. tx.SQL on the same db)
+// are then commited atomically with the record.
+type IdempotencyStore interface {
+	// Lock stores in progress rec unless an unexpired record with the
+	// same key exists, in which case the existing one is returned.
+	// In progress records past their LockedUntil are taken over.
+	Lock(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error)
+
+	// Complete stores response of a locked record, returning
+	// ErrIdempotencyLockLost if the lock was taken over
+	Complete(ctx context.Context, rec *IdempotencyRecord) error
+
+	// Unlock removes locked record when its response could not be
+	// stored, so that the request can be retried. Records whose
+	// lock was taken over are left as they are.
+	Unlock(ctx context.Context, rec *IdempotencyRecord) error
+}
+
+// IdempotencyOption represents idempotency option
+type IdempotencyOption func(*idempotencyCfg)
+
+// WithIdempotency creates a new idempotency adapter for unsafe endpoints.
+// Requests carrying Idempotency-Key header are recorded in store along
+// with a fingerprint of the request, and their response once completed.
+//
+// Retried requests get stored response replayed, while requests with
+// the same key received while the first one is in progress get 409.
+// Reusing a key for a different request results in 422. Keys are scoped
+// to authenticated principal (if any) and records expire after 24h unless
+// set otherwise with WithIdempotencyTTL. Requests left in progress
+// (eg. by a crashed process) can be retried once lock timeout
+// (1m unless set otherwise with WithIdempotencyLockTimeout) passes.
+//
+// Responses with 5xx status are not stored, so such requests can be
+// retried with the same key.
+func WithIdempotency(store IdempotencyStore, opts ...IdempotencyOption) http.Adapter {
+	cfg := idempotencyCfg{
+		ttl:         24 * time.Hour,
+		lockTimeout: time.Minute,
+		maxBody:     1 << 20,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if safeMethod(r.Method) || (key == "" && !cfg.required) {
+				h(c, w, r)
+				return
+			}
+
+			if key == "" || len(key) > 255 {
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusBadRequest, fmt.Errorf("idempotency: %s header required (up to 255 characters)", IdempotencyKeyHeader)))
+				return
+			}
+			if p := PrincipalFromCtx(c); p != nil {
+				key = p.ID + ":" + key
+			}
+
+			body, err := ioutil.ReadAll(gohttp.MaxBytesReader(w, r.Body, cfg.maxBody))
+			if err != nil {
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusBadRequest, fmt.Errorf("idempotency: could not read request body: %v", err)))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			// Lock time is truncated to microseconds, so that
+			// it survives the round trip to sql stores intact
+			now := time.Now()
+			rec := IdempotencyRecord{
+				Key:         key,
+				Fingerprint: fingerprint(r, body),
+				LockedUntil: now.Add(cfg.lockTimeout).Truncate(time.Microsecond),
+				ExpiresAt:   now.Add(cfg.ttl),
+			}
+
+			stored, err := store.Lock(c, &rec)
+			if err != nil {
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusInternalServerError, fmt.Errorf("idempotency: could not lock key: %v", err)))
+				return
+			}
+			if stored != nil {
+				replay(w, r, stored, &rec)
+				return
+			}
+
+			buf := &bufferedWriter{header: make(gohttp.Header)}
+
+			run := func(ctx context.Context) error {
+				h(ctx, buf, r.WithContext(ctx))
+				buf.WriteHeader(gohttp.StatusOK)
+				if buf.status >= 500 {
+					return errNotRecorded
+				}
+				rec.Status = buf.status
+				rec.Header = buf.header
+				rec.Body = buf.body.Bytes()
+				return store.Complete(ctx, &rec)
+			}
+
+			if t, ok := store.(tx.Transactional); ok {
+				err = t.RunTx(c, run)
+			} else {
+				err = run(c)
+			}
+
+			if err != nil {
+				// Unlock with a fresh context, since the request
+				// one might have been canceled by now
+				store.Unlock(context.Background(), &rec)
+
+				// Failed business transaction is reported by the handler
+				// itself, anything else means the response was not recorded
+				if err != errNotRecorded && !errors.Is(err, tx.ErrRollbackOnly) {
+					respond.WithJSON(w, r, http.NewError(gohttp.StatusInternalServerError, fmt.Errorf("idempotency: could not store response: %v", err)))
+					return
+				}
+			}
+
+			buf.writeTo(w)
+		}
+	}
+}
+
+var errNotRecorded = fmt.Errorf("idempotency: server error response not recorded")
+
+func replay(w gohttp.ResponseWriter, r *gohttp.Request, stored, rec *IdempotencyRecord) {
+	if stored.Fingerprint != rec.Fingerprint {
+		respond.WithJSON(w, r, http.NewError(gohttp.StatusUnprocessableEntity, fmt.Errorf("idempotency: key already used for a different request")))
+		return
+	}
+	if stored.Status == 0 {
+		respond.WithJSON(w, r, http.NewError(gohttp.StatusConflict, fmt.Errorf("idempotency: request with the same key is in progress")))
+		return
+	}
+
+	for k, v := range stored.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+func fingerprint(r *gohttp.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bufferedWriter holds the response back until it is recorded
+// (and the transaction is commited) before it is sent. Headers are
+// kept apart as well, so that they do not leak into error responses.
+type bufferedWriter struct {
+	header gohttp.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() gohttp.Header { return b.header }
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(gohttp.StatusOK)
+	return b.body.Write(p)
+}
+
+// writeTo sends buffered response to w, along with the headers set by the handler
+func (b *bufferedWriter) writeTo(w gohttp.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+type idempotencyCfg struct {
+	ttl         time.Duration
+	lockTimeout time.Duration
+	maxBody     int64
+	required    bool
+}
+
+// WithIdempotencyTTL sets how long records are kept for (24h by default)
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(cfg *idempotencyCfg) {
+		cfg.ttl = ttl
+	}
+}
+
+// WithIdempotencyLockTimeout sets how long a request can be in progress
+// before another one with the same key takes it over (1m by default).
+// It should be longer than the slowest request takes to complete.
+func WithIdempotencyLockTimeout(d time.Duration) IdempotencyOption {
+	return func(cfg *idempotencyCfg) {
+		cfg.lockTimeout = d
+	}
+}
+
+// WithIdempotencyKeyRequired makes unsafe requests without
+// Idempotency-Key header fail with 400
+func WithIdempotencyKeyRequired() IdempotencyOption {
+	return func(cfg *idempotencyCfg) {
+		cfg.required = true
+	}
+}
+
+// WithIdempotencyMaxBody sets max request body size in bytes (1MB by default)
+func WithIdempotencyMaxBody(n int64) IdempotencyOption {
+	return func(cfg *idempotencyCfg) {
+		cfg.maxBody = n
+	}
+}
+
+// NewMemoryIdempotencyStore creates in-memory idempotency store,
+// suitable for single instance services and tests
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: map[string]IdempotencyRecord{},
+	}
+}
+
+// MemoryIdempotencyStore represents in-memory idempotency store
+type MemoryIdempotencyStore struct {
+	m         sync.Mutex
+	records   map[string]IdempotencyRecord
+	lastSweep time.Time
+}
+
+// Lock stores in progress rec unless unexpired record with the same key
+// exists, taking over in progress records past their LockedUntil
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if stored, ok := s.records[rec.Key]; ok && now.Before(stored.ExpiresAt) &&
+		(stored.Status != 0 || now.Before(stored.LockedUntil)) {
+		return &stored, nil
+	}
+
+	s.records[rec.Key] = *rec
+
+	return nil, nil
+}
+
+// Complete stores response of a locked record
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, rec *IdempotencyRecord) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	stored, ok := s.records[rec.Key]
+	if !ok || stored.Status != 0 || !stored.LockedUntil.Equal(rec.LockedUntil) {
+		return ErrIdempotencyLockLost
+	}
+
+	stored.Status = rec.Status
+	stored.Header = rec.Header.Clone()
+	stored.Body = append([]byte(nil), rec.Body...)
+	s.records[rec.Key] = stored
+
+	return nil
+}
+
+// Unlock removes locked record unless its lock was taken over
+func (s *MemoryIdempotencyStore) Unlock(_ context.Context, rec *IdempotencyRecord) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if stored, ok := s.records[rec.Key]; ok && stored.LockedUntil.Equal(rec.LockedUntil) {
+		delete(s.records, rec.Key)
+	}
+
+	return nil
+}
+
+// sweep removes expired records at most once a minute
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for k, rec := range s.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.records, k)
+		}
+	}
+}
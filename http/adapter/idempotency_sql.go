@@ -0,0 +1,185 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tonto/kit/tx"
+)
+
+// NewSQLIdempotencyStore creates postgres backed idempotency store keeping
+// records in a table with the following schema:
+//
+//	CREATE TABLE idempotency_keys (
+//		key          TEXT PRIMARY KEY,
+//		fingerprint  TEXT NOT NULL,
+//		status       INTEGER NOT NULL DEFAULT 0,
+//		header       JSONB,
+//		body         BYTEA,
+//		locked_until TIMESTAMPTZ NOT NULL,
+//		expires_at   TIMESTAMPTZ NOT NULL
+//	);
+//
+// Handlers are run in a transaction on db, which the record is completed in.
+// Repositories embedding tx.SQL with the same db join it, so business changes
+// and the record are commited (or rolled back) together, eg. for
+// the TransferMoney example:
+//
+//	svc := example.NewAccountService(postgres.NewAccount(db))
+//	store := adapter.NewSQLIdempotencyStore(db, "idempotency_keys")
+//
+//	transfers.RegisterHandler("POST", "/", transfer, adapter.WithIdempotency(store))
+func NewSQLIdempotencyStore(db *sql.DB, table string) *SQLIdempotencyStore {
+	return &SQLIdempotencyStore{
+		SQL:   tx.SQL{DB: db},
+		table: table,
+	}
+}
+
+// SQLIdempotencyStore represents postgres idempotency store
+type SQLIdempotencyStore struct {
+	tx.SQL
+	table string
+}
+
+// Lock inserts in progress rec, unless unexpired record
+// with the same key exists, in which case it is returned.
+// In progress records past their locked_until are taken over.
+// Lock is always run outside of the transaction so that
+// concurrent requests see the key locked right away.
+func (s *SQLIdempotencyStore) Lock(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	for {
+		locked, err := s.insert(ctx, rec)
+		if err != nil || locked {
+			return nil, err
+		}
+
+		stored, err := s.get(ctx, rec.Key)
+		if err == sql.ErrNoRows {
+			// Existing record was deleted in the meantime (unlocked
+			// or expired), so the key can be locked again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return stored, nil
+	}
+}
+
+// insert inserts in progress rec (or takes over expired or stale one),
+// reporting whether it did so
+func (s *SQLIdempotencyStore) insert(ctx context.Context, rec *IdempotencyRecord) (bool, error) {
+	res, err := s.DB.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`INSERT INTO %[1]s (key, fingerprint, status, header, body, locked_until, expires_at)
+			VALUES ($1, $2, 0, NULL, NULL, $3, $4)
+			ON CONFLICT (key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint, status = 0, header = NULL, body = NULL,
+				locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+			WHERE %[1]s.expires_at <= $5 OR (%[1]s.status = 0 AND %[1]s.locked_until <= $5)`,
+			s.table,
+		),
+		rec.Key, rec.Fingerprint, rec.LockedUntil, rec.ExpiresAt, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *SQLIdempotencyStore) get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	var (
+		stored IdempotencyRecord
+		header []byte
+	)
+	err := s.DB.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`SELECT key, fingerprint, status, header, body, locked_until, expires_at FROM %s WHERE key = $1`, s.table),
+		key,
+	).Scan(&stored.Key, &stored.Fingerprint, &stored.Status, &header, &stored.Body, &stored.LockedUntil, &stored.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		if err := json.Unmarshal(header, &stored.Header); err != nil {
+			return nil, fmt.Errorf("could not decode stored header: %v", err)
+		}
+	}
+
+	return &stored, nil
+}
+
+// Complete stores response of a locked record, within
+// the transaction found in ctx if any
+func (s *SQLIdempotencyStore) Complete(ctx context.Context, rec *IdempotencyRecord) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.execer(ctx).ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`UPDATE %s SET status = $2, header = $3, body = $4
+			WHERE key = $1 AND status = 0 AND locked_until = $5`,
+			s.table,
+		),
+		rec.Key, rec.Status, header, rec.Body, rec.LockedUntil,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+// Unlock deletes locked record unless its lock was taken over
+func (s *SQLIdempotencyStore) Unlock(ctx context.Context, rec *IdempotencyRecord) error {
+	_, err := s.DB.ExecContext(
+		ctx,
+		fmt.Sprintf(`DELETE FROM %s WHERE key = $1 AND locked_until = $2`, s.table),
+		rec.Key, rec.LockedUntil,
+	)
+	return err
+}
+
+// DeleteExpired deletes expired records, and is meant to
+// be run periodically to keep the table small
+func (s *SQLIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.DB.ExecContext(
+		ctx,
+		fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= $1`, s.table),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type execer interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLIdempotencyStore) execer(ctx context.Context) execer {
+	if t, ok := tx.Current(ctx); ok {
+		if sqlTx, ok := t.Unwrap().(*sql.Tx); ok {
+			return sqlTx
+		}
+	}
+	return s.DB
+}
@@ -0,0 +1,358 @@
+package adapter_test
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/tx"
+)
+
+func TestWithIdempotency(t *testing.T) {
+	type req struct {
+		method string
+		key    string
+		body   string
+	}
+
+	cases := []struct {
+		name        string
+		opts        []adapter.IdempotencyOption
+		status      int
+		reqs        []req
+		wait        time.Duration
+		wantCodes   []int
+		wantCalls   int
+		wantReplays []bool
+	}{
+		{
+			name:        "test replay",
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k1", body: `{"amount":10}`}},
+			wantCodes:   []int{201, 201},
+			wantCalls:   1,
+			wantReplays: []bool{false, true},
+		},
+		{
+			name:        "test different keys",
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k2", body: `{"amount":10}`}},
+			wantCodes:   []int{201, 201},
+			wantCalls:   2,
+			wantReplays: []bool{false, false},
+		},
+		{
+			name:        "test key reused for different request",
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k1", body: `{"amount":20}`}},
+			wantCodes:   []int{201, 422},
+			wantCalls:   1,
+			wantReplays: []bool{false, false},
+		},
+		{
+			name:        "test expired record",
+			opts:        []adapter.IdempotencyOption{adapter.WithIdempotencyTTL(10 * time.Millisecond)},
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k1", body: `{"amount":10}`}},
+			wait:        20 * time.Millisecond,
+			wantCodes:   []int{201, 201},
+			wantCalls:   2,
+			wantReplays: []bool{false, false},
+		},
+		{
+			name:        "test server error not recorded",
+			status:      503,
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k1", body: `{"amount":10}`}},
+			wantCodes:   []int{503, 503},
+			wantCalls:   2,
+			wantReplays: []bool{false, false},
+		},
+		{
+			name:        "test client error recorded",
+			status:      400,
+			reqs:        []req{{key: "k1", body: `{"amount":10}`}, {key: "k1", body: `{"amount":10}`}},
+			wantCodes:   []int{400, 400},
+			wantCalls:   1,
+			wantReplays: []bool{false, true},
+		},
+		{
+			name:        "test no key",
+			reqs:        []req{{body: `{"amount":10}`}, {body: `{"amount":10}`}},
+			wantCodes:   []int{201, 201},
+			wantCalls:   2,
+			wantReplays: []bool{false, false},
+		},
+		{
+			name:        "test key required",
+			opts:        []adapter.IdempotencyOption{adapter.WithIdempotencyKeyRequired()},
+			reqs:        []req{{body: `{"amount":10}`}},
+			wantCodes:   []int{400},
+			wantCalls:   0,
+			wantReplays: []bool{false},
+		},
+		{
+			name:        "test safe method",
+			opts:        []adapter.IdempotencyOption{adapter.WithIdempotencyKeyRequired()},
+			reqs:        []req{{method: "GET"}, {method: "GET", key: "k1"}, {method: "GET", key: "k1"}},
+			wantCodes:   []int{201, 201, 201},
+			wantCalls:   3,
+			wantReplays: []bool{false, false, false},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls := 0
+			status := c.status
+			if status == 0 {
+				status = 201
+			}
+
+			hdlr := adapter.WithIdempotency(adapter.NewMemoryIdempotencyStore(), c.opts...)(
+				func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+					calls++
+					w.Header().Set("Location", fmt.Sprintf("/transfers/%d", calls))
+					respond.WithJSON(w, r, http.NewResponse(fmt.Sprintf("transfer %d", calls), status))
+				},
+			)
+
+			var first string
+
+			for i, rq := range c.reqs {
+				if i > 0 {
+					time.Sleep(c.wait)
+				}
+
+				method := "POST"
+				if rq.method != "" {
+					method = rq.method
+				}
+				r := httptest.NewRequest(method, "/transfers", strings.NewReader(rq.body))
+				if rq.key != "" {
+					r.Header.Set("Idempotency-Key", rq.key)
+				}
+				w := httptest.NewRecorder()
+
+				hdlr(context.Background(), w, r)
+
+				assert.Equal(t, c.wantCodes[i], w.Code)
+				assert.Equal(t, c.wantReplays[i], w.Header().Get("Idempotent-Replayed") == "true")
+				if c.wantReplays[i] {
+					assert.Equal(t, first, w.Body.String())
+					assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+					assert.Equal(t, "/transfers/1", w.Header().Get("Location"))
+				}
+				if i == 0 {
+					first = w.Body.String()
+				}
+			}
+
+			assert.Equal(t, c.wantCalls, calls)
+		})
+	}
+}
+
+func TestWithIdempotency_Concurrent(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	hdlr := adapter.WithIdempotency(adapter.NewMemoryIdempotencyStore())(
+		func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			close(started)
+			<-release
+			respond.WithJSON(w, r, "transfered")
+		},
+	)
+
+	newReq := func() *gohttp.Request {
+		r := httptest.NewRequest("POST", "/transfers", strings.NewReader(`{"amount":10}`))
+		r.Header.Set("Idempotency-Key", "k1")
+		return r
+	}
+
+	var wg sync.WaitGroup
+	first := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hdlr(context.Background(), first, newReq())
+	}()
+
+	<-started
+
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, newReq())
+	assert.Equal(t, 409, w.Code)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 200, first.Code)
+
+	w = httptest.NewRecorder()
+	hdlr(context.Background(), w, newReq())
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+}
+
+func TestWithIdempotency_StaleLock(t *testing.T) {
+	calls := 0
+	hdlr := adapter.WithIdempotency(
+		adapter.NewMemoryIdempotencyStore(),
+		adapter.WithIdempotencyLockTimeout(20*time.Millisecond),
+	)(
+		func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			calls++
+			if calls == 1 {
+				// Neither completes nor unlocks the record, as if the process crashed
+				panic("crashed")
+			}
+			respond.WithJSON(w, r, http.NewResponse("transfered", 201))
+		},
+	)
+
+	call := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/transfers", strings.NewReader(`{"amount":10}`))
+		r.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		hdlr(context.Background(), w, r)
+		return w
+	}
+
+	assert.Panics(t, func() { call() })
+
+	assert.Equal(t, 409, call().Code)
+
+	time.Sleep(30 * time.Millisecond)
+
+	assert.Equal(t, 201, call().Code)
+	assert.Equal(t, 2, calls)
+
+	w := call()
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMemoryIdempotencyStore_LockLost(t *testing.T) {
+	store := adapter.NewMemoryIdempotencyStore()
+	ctx := context.Background()
+
+	stale := adapter.IdempotencyRecord{
+		Key:         "k1",
+		LockedUntil: time.Now().Add(-time.Second),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	stored, err := store.Lock(ctx, &stale)
+	assert.Nil(t, stored)
+	assert.Nil(t, err)
+
+	rec := stale
+	rec.LockedUntil = time.Now().Add(time.Minute)
+	stored, err = store.Lock(ctx, &rec)
+	assert.Nil(t, stored)
+	assert.Nil(t, err)
+
+	// Request whose lock was taken over can neither complete nor unlock the record
+	stale.Status = 201
+	assert.Equal(t, adapter.ErrIdempotencyLockLost, store.Complete(ctx, &stale))
+	assert.Nil(t, store.Unlock(ctx, &stale))
+
+	rec.Status = 201
+	assert.Nil(t, store.Complete(ctx, &rec))
+}
+
+func TestWithIdempotency_Transactional(t *testing.T) {
+	cases := []struct {
+		name         string
+		commitErr    error
+		wantCode     int
+		wantLocation string
+		wantCommits  int
+		wantCalls    int
+	}{
+		{
+			name:         "test record completed in transaction",
+			wantCode:     200,
+			wantLocation: "/transfers/1",
+			wantCommits:  1,
+			wantCalls:    1,
+		},
+		{
+			name:      "test commit error",
+			commitErr: fmt.Errorf("connection reset"),
+			wantCode:  500,
+			wantCalls: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &txStore{
+				MemoryIdempotencyStore: adapter.NewMemoryIdempotencyStore(),
+				commitErr:              c.commitErr,
+			}
+
+			calls := 0
+			hdlr := adapter.WithIdempotency(store)(
+				func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+					calls++
+					_, inTx := tx.Current(ctx)
+					assert.True(t, inTx)
+					w.Header().Set("Location", "/transfers/1")
+					respond.WithJSON(w, r, "transfered")
+				},
+			)
+
+			var w, first *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest("POST", "/transfers", strings.NewReader(`{"amount":10}`))
+				r.Header.Set("Idempotency-Key", "k1")
+				w = httptest.NewRecorder()
+				hdlr(context.Background(), w, r)
+				if i == 0 {
+					first = w
+				}
+			}
+
+			assert.Equal(t, c.wantCode, w.Code)
+			// Headers set by the handler are not sent along with the error
+			assert.Equal(t, c.wantLocation, first.Header().Get("Location"))
+			assert.Equal(t, c.wantCommits, store.commits)
+			assert.Equal(t, c.wantCalls, calls)
+			assert.True(t, store.completedInTx)
+		})
+	}
+}
+
+type txStore struct {
+	*adapter.MemoryIdempotencyStore
+	commitErr     error
+	commits       int
+	completedInTx bool
+}
+
+func (s *txStore) RunTx(ctx context.Context, f func(context.Context) error) error {
+	t, _ := tx.Begin(ctx, func(context.Context) (interface{}, error) { return "tx", nil })
+	return tx.Run(ctx, s, t, f)
+}
+
+func (s *txStore) Complete(ctx context.Context, rec *adapter.IdempotencyRecord) error {
+	_, s.completedInTx = tx.Current(ctx)
+	return s.MemoryIdempotencyStore.Complete(ctx, rec)
+}
+
+func (s *txStore) Commit(*tx.Tx) error {
+	if s.commitErr != nil {
+		return s.commitErr
+	}
+	s.commits++
+	return nil
+}
+
+func (s *txStore) Rollback(*tx.Tx) error { return nil }
@@ -0,0 +1,314 @@
+package adapter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	gohttp "net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWKSOption represents JWKS option
+type JWKSOption func(*JWKS)
+
+// NewJWKS creates a new JWKS key source loading JSON Web Key Set from
+// src, which can either be an http(s) url or a file path.
+// Keys are loaded upon creation and cached, cache is refreshed
+// once it gets older than refresh interval (1h by default) or when
+// a token with an unknown kid is seen. Refreshes are attempted at most
+// once per min refresh interval (1m by default).
+func NewJWKS(src string, opts ...JWKSOption) (*JWKS, error) {
+	ks := JWKS{
+		src:        src,
+		client:     &gohttp.Client{Timeout: 10 * time.Second},
+		refresh:    time.Hour,
+		minRefresh: time.Minute,
+	}
+
+	for _, o := range opts {
+		o(&ks)
+	}
+
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return &ks, nil
+}
+
+// JWKS represents JSON Web Key Set JWTKeySource
+type JWKS struct {
+	src        string
+	client     *gohttp.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	m         sync.RWMutex
+	keys      map[string]jwk
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// JWTKey returns the key with a matching kid, refreshing
+// the key set if it is stale or the kid is unknown
+func (ks *JWKS) JWTKey(ctx context.Context, kid string, alg string) (interface{}, error) {
+	ks.m.RLock()
+	k, ok := ks.lookup(kid)
+	stale := time.Since(ks.fetchedAt) > ks.refresh
+	refresh := (stale || !ok) && time.Since(ks.triedAt) >= ks.minRefresh
+	ks.m.RUnlock()
+
+	if refresh {
+		// Mark the attempt right away so concurrent requests don't
+		// trigger refreshes of their own, checking it again since
+		// another request might have done so in the meantime
+		ks.m.Lock()
+		refresh = time.Since(ks.triedAt) >= ks.minRefresh
+		if refresh {
+			ks.triedAt = time.Now()
+		}
+		ks.m.Unlock()
+	}
+
+	if refresh {
+		// On refresh error keep serving the cached keys
+		// until they get replaced
+		if err := ks.load(ctx); err == nil {
+			ks.m.RLock()
+			k, ok = ks.lookup(kid)
+			ks.m.RUnlock()
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("key %q can not be used with %s", kid, alg)
+	}
+
+	return k.key, nil
+}
+
+func (ks *JWKS) lookup(kid string) (jwk, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// Refresh (re)loads the key set from its source
+func (ks *JWKS) Refresh(ctx context.Context) error {
+	ks.m.Lock()
+	ks.triedAt = time.Now()
+	ks.m.Unlock()
+
+	return ks.load(ctx)
+}
+
+func (ks *JWKS) load(ctx context.Context) error {
+	data, err := ks.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("jwks: could not load key set: %v", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("jwks: could not parse key set: %v", err)
+	}
+
+	ks.m.Lock()
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	ks.m.Unlock()
+
+	return nil
+}
+
+func (ks *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(ks.src, "http://") && !strings.HasPrefix(ks.src, "https://") {
+		return ioutil.ReadFile(ks.src)
+	}
+
+	req, err := gohttp.NewRequest("GET", ks.src, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ks.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != gohttp.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// WithJWKSRefresh sets the interval after which cached keys are refreshed
+func WithJWKSRefresh(d time.Duration) JWKSOption {
+	return func(ks *JWKS) {
+		ks.refresh = d
+	}
+}
+
+// WithJWKSMinRefresh sets the minimum interval between two refresh attempts
+func WithJWKSMinRefresh(d time.Duration) JWKSOption {
+	return func(ks *JWKS) {
+		ks.minRefresh = d
+	}
+}
+
+// WithJWKSClient sets http client used to fetch remote key sets
+func WithJWKSClient(c *gohttp.Client) JWKSOption {
+	return func(ks *JWKS) {
+		ks.client = c
+	}
+}
+
+// minRSABits is the minimum size of RSA key modulus accepted
+const minRSABits = 2048
+
+type jwk struct {
+	alg string
+	key interface{}
+}
+
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	// Keys which can not be used (eg. of unsupported type or curve)
+	// are skipped, failing the set only if none is left (RFC 7517 section 5)
+	keys := make(map[string]jwk)
+	var errs []string
+	for _, rk := range set.Keys {
+		if rk.Use != "" && rk.Use != "sig" {
+			continue
+		}
+
+		key, err := rk.key()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("key %q: %v", rk.Kid, err))
+			continue
+		}
+
+		keys[rk.Kid] = jwk{alg: rk.Alg, key: key}
+	}
+
+	if len(keys) == 0 {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("no usable keys: %s", strings.Join(errs, "; "))
+		}
+		return nil, fmt.Errorf("no usable keys")
+	}
+
+	return keys, nil
+}
+
+func (rk rawJWK) key() (interface{}, error) {
+	switch rk.Kty {
+	case "RSA":
+		n, err := decodeB64Int(rk.N)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key too small: %d bits (at least %d required)", n.BitLen(), minRSABits)
+		}
+		e, err := decodeB64Int(rk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() <= 1 || e.Int64() > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var crv elliptic.Curve
+		switch rk.Crv {
+		case "P-256":
+			crv = elliptic.P256()
+		case "P-384":
+			crv = elliptic.P384()
+		case "P-521":
+			crv = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", rk.Crv)
+		}
+		x, err := decodeB64Int(rk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeB64Int(rk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !crv.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: crv, X: x, Y: y}, nil
+
+	case "OKP":
+		if rk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", rk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(rk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(rk.K)
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %s", rk.Kty)
+}
+
+func decodeB64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
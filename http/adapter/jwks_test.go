@@ -0,0 +1,274 @@
+package adapter_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+)
+
+func TestJWKS_KeySelection(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	srv := newJWKSServer(
+		rsaJWK("rsa-1", &rsaKey.PublicKey),
+		ecJWK("ec-1", &ecKey.PublicKey),
+		edJWK("ed-1", edPub),
+	)
+	defer srv.Close()
+
+	ks, err := adapter.NewJWKS(srv.URL)
+	assert.Nil(t, err)
+
+	apt := adapter.WithJWTAuthKeys(
+		[]adapter.JWTAlg{adapter.JWTAlgRS256, adapter.JWTAlgES384, adapter.JWTAlgEdDSA},
+		ks,
+		func(ctx context.Context, token string, claims map[string]interface{}) error { return nil },
+	)
+
+	cases := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "test rsa kid", token: signTestToken(adapter.JWTAlgRS256, rsaKey, "rsa-1"), want: 200},
+		{name: "test ec kid", token: signTestToken(adapter.JWTAlgES384, ecKey, "ec-1"), want: 200},
+		{name: "test ed kid", token: signTestToken(adapter.JWTAlgEdDSA, edKey, "ed-1"), want: 200},
+		{name: "test wrong kid", token: signTestToken(adapter.JWTAlgRS256, rsaKey, "ec-1"), want: 401},
+		{name: "test unknown kid", token: signTestToken(adapter.JWTAlgRS256, rsaKey, "rsa-2"), want: 401},
+		{name: "test no kid", token: signTestToken(adapter.JWTAlgRS256, rsaKey, ""), want: 401},
+		{name: "test key alg mismatch", token: signTestToken(adapter.JWTAlgPS256, rsaKey, "rsa-1"), want: 401},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hdlr := apt(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+c.token)
+
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, req)
+
+			assert.Equal(t, c.want, w.Code)
+		})
+	}
+}
+
+func TestJWKS_Rotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	srv := newJWKSServer(rsaJWK("k1", &oldKey.PublicKey))
+	defer srv.Close()
+
+	ks, err := adapter.NewJWKS(srv.URL, adapter.WithJWKSMinRefresh(50*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, srv.hits())
+
+	_, err = ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, srv.hits(), "known kid should be served from cache")
+
+	srv.setKeys(rsaJWK("k1", &oldKey.PublicKey), rsaJWK("k2", &newKey.PublicKey))
+
+	// Set was just fetched so refresh is rate limited
+	_, err = ks.JWTKey(context.Background(), "k2", "RS256")
+	assert.NotNil(t, err)
+	_, err = ks.JWTKey(context.Background(), "k3", "RS256")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, srv.hits())
+
+	time.Sleep(60 * time.Millisecond)
+
+	key, err := ks.JWTKey(context.Background(), "k2", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, &newKey.PublicKey, key)
+	assert.Equal(t, 2, srv.hits())
+}
+
+func TestJWKS_Refresh(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	srv := newJWKSServer(rsaJWK("k1", &key.PublicKey))
+	defer srv.Close()
+
+	ks, err := adapter.NewJWKS(
+		srv.URL,
+		adapter.WithJWKSRefresh(20*time.Millisecond),
+		adapter.WithJWKSMinRefresh(10*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, srv.hits())
+
+	// Stale keys are kept if refresh fails
+	srv.Close()
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+}
+
+func TestJWKS_File(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	dir, _ := ioutil.TempDir("", "jwks")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "jwks.json")
+	data, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{rsaJWK("k1", &key.PublicKey)}})
+	ioutil.WriteFile(path, data, 0600)
+
+	ks, err := adapter.NewJWKS(path)
+	assert.Nil(t, err)
+
+	k, err := ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, &key.PublicKey, k)
+
+	_, err = adapter.NewJWKS(filepath.Join(dir, "missing.json"))
+	assert.NotNil(t, err)
+}
+
+func TestJWKS_UnsupportedKeys(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	pq := map[string]string{"kty": "AKP", "kid": "pq-1", "alg": "ML-DSA-65"}
+	x448 := map[string]string{"kty": "OKP", "kid": "ed448-1", "crv": "Ed448", "x": "AA"}
+
+	srv := newJWKSServer(pq, rsaJWK("k1", &key.PublicKey), x448)
+	defer srv.Close()
+
+	ks, err := adapter.NewJWKS(srv.URL, adapter.WithJWKSMinRefresh(time.Millisecond))
+	assert.Nil(t, err)
+
+	k, err := ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, &key.PublicKey, k)
+
+	_, err = ks.JWTKey(context.Background(), "pq-1", "ML-DSA-65")
+	assert.NotNil(t, err)
+
+	srv.setKeys(pq, x448)
+	_, err = adapter.NewJWKS(srv.URL)
+	assert.NotNil(t, err)
+}
+
+func TestJWKS_InvalidRSAKeys(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	exp := func(kid string, e []byte) map[string]string {
+		k := rsaJWK(kid, &key.PublicKey)
+		k["e"] = b64(e)
+		return k
+	}
+
+	srv := newJWKSServer(
+		rsaJWK("k1", &key.PublicKey),
+		rsaJWK("weak", &weak.PublicKey),
+		exp("e-1", []byte{1}),
+		exp("e-0", nil),
+		exp("e-overflow", []byte{1, 0, 0, 0, 0, 0, 0, 0, 1}),
+		exp("e-int32-overflow", []byte{1, 0, 0, 0, 1}),
+	)
+	defer srv.Close()
+
+	ks, err := adapter.NewJWKS(srv.URL, adapter.WithJWKSMinRefresh(time.Hour))
+	assert.Nil(t, err)
+
+	k, err := ks.JWTKey(context.Background(), "k1", "RS256")
+	assert.Nil(t, err)
+	assert.Equal(t, &key.PublicKey, k)
+
+	for _, kid := range []string{"weak", "e-1", "e-0", "e-overflow", "e-int32-overflow"} {
+		_, err := ks.JWTKey(context.Background(), kid, "RS256")
+		assert.EqualError(t, err, fmt.Sprintf("unknown key id: %q", kid))
+	}
+}
+
+type jwksServer struct {
+	*httptest.Server
+	m     sync.Mutex
+	keys  []interface{}
+	count int
+}
+
+func newJWKSServer(keys ...interface{}) *jwksServer {
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		s.m.Lock()
+		defer s.m.Unlock()
+		s.count++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) hits() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.count
+}
+
+func rsaJWK(kid string, k *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   b64(k.N.Bytes()),
+		"e":   b64(big.NewInt(int64(k.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, k *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": k.Curve.Params().Name,
+		"x":   b64(k.X.Bytes()),
+		"y":   b64(k.Y.Bytes()),
+	}
+}
+
+func edJWK(kid string, k ed25519.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "OKP",
+		"kid": kid,
+		"crv": "Ed25519",
+		"x":   b64(k),
+	}
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
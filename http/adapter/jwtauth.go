@@ -4,10 +4,8 @@ import (
 	"context"
 	"fmt"
 	gohttp "net/http"
-	"strings"
 
 	"github.com/tonto/kit/http"
-	"github.com/tonto/kit/http/respond"
 	jwt "gopkg.in/dgrijalva/jwt-go.v3"
 )
 
@@ -20,11 +18,28 @@ import (
 type AuthCallbackFunc func(context.Context, string, map[string]interface{}) error
 
 // JWTAlg represents token signing alg type
-type JWTAlg *jwt.SigningMethodHMAC
+type JWTAlg jwt.SigningMethod
+
+// JWTKeySource provides keys used to verify token signatures.
+// kid is the key id found in token header (may be empty) and
+// alg is the name of the alg token was signed with.
+type JWTKeySource interface {
+	JWTKey(ctx context.Context, kid string, alg string) (interface{}, error)
+}
 
 // JWTTokenKey is used to store token to context
 const JWTTokenKey = "tonto_http_token_key"
 
+// JWTClaimsKey is used to store verified token claims to context
+const JWTClaimsKey = "tonto_http_claims_key"
+
+// JWTTypedClaimsKey is used to store claims decoded into
+// the type set by WithJWTClaimsAs to context
+const JWTTypedClaimsKey = "tonto_http_typed_claims_key"
+
+// JWTOption represents jwt auth adapter option
+type JWTOption func(*jwtCfg)
+
 var (
 	// JWTAlgHS256 represents HMAC SHA256 token signing alg
 	JWTAlgHS256 = jwt.SigningMethodHS256
@@ -34,62 +49,136 @@ var (
 
 	// JWTAlgHS512 represents HMAC SHA512 token signing alg
 	JWTAlgHS512 = jwt.SigningMethodHS512
+
+	// JWTAlgRS256 represents RSASSA-PKCS1-v1_5 SHA256 token signing alg
+	JWTAlgRS256 = jwt.SigningMethodRS256
+
+	// JWTAlgRS384 represents RSASSA-PKCS1-v1_5 SHA384 token signing alg
+	JWTAlgRS384 = jwt.SigningMethodRS384
+
+	// JWTAlgRS512 represents RSASSA-PKCS1-v1_5 SHA512 token signing alg
+	JWTAlgRS512 = jwt.SigningMethodRS512
+
+	// JWTAlgPS256 represents RSASSA-PSS SHA256 token signing alg
+	JWTAlgPS256 = jwt.SigningMethodPS256
+
+	// JWTAlgPS384 represents RSASSA-PSS SHA384 token signing alg
+	JWTAlgPS384 = jwt.SigningMethodPS384
+
+	// JWTAlgPS512 represents RSASSA-PSS SHA512 token signing alg
+	JWTAlgPS512 = jwt.SigningMethodPS512
+
+	// JWTAlgES256 represents ECDSA P-256 SHA256 token signing alg
+	JWTAlgES256 = jwt.SigningMethodES256
+
+	// JWTAlgES384 represents ECDSA P-384 SHA384 token signing alg
+	JWTAlgES384 = jwt.SigningMethodES384
+
+	// JWTAlgES512 represents ECDSA P-521 SHA512 token signing alg
+	JWTAlgES512 = jwt.SigningMethodES512
+
+	// JWTAlgEdDSA represents Ed25519 token signing alg
+	JWTAlgEdDSA = signingMethodEdDSA
 )
 
 // WithJWTAuth represents jwt authentication adapter
 // It looks for bearer token in Authorization header, and if
 // found tries to validate it against provided alg and key, if
-// successful callback func is called to perform client side auth check.
-func WithJWTAuth(alg JWTAlg, key []byte, callback AuthCallbackFunc) http.Adapter {
+// successful callback func (if not nil) is called to perform client side auth check.
+// key is a secret for HMAC algs, or a PEM encoded public key for
+// the asymmetric ones (RS*, PS*, ES*, EdDSA).
+// Token, its claims and the principal it represents are stored
+// to context (see JWTClaimsFromCtx and PrincipalFromCtx).
+// It panics if key can not be parsed.
+func WithJWTAuth(alg JWTAlg, key []byte, callback AuthCallbackFunc, opts ...JWTOption) http.Adapter {
+	k, err := ParseJWTKey(alg, key)
+	if err != nil {
+		panic(fmt.Sprintf("adapter: invalid jwt verification key: %v", err))
+	}
+	return WithJWTAuthKeys([]JWTAlg{alg}, JWTKeys{"": k}, callback, opts...)
+}
+
+// WithJWTAuthKeys represents jwt authentication adapter which
+// accepts tokens signed with any of provided algs and verifies them with
+// a key looked up by token kid from provided key source (eg. JWKS).
+// Apart from that it behaves the same as WithJWTAuth.
+func WithJWTAuthKeys(algs []JWTAlg, keys JWTKeySource, callback AuthCallbackFunc, opts ...JWTOption) http.Adapter {
+	cfg := jwtCfg{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.onError == nil {
+		cfg.onError = cfg.writeError
+	}
+	parser := &jwt.Parser{
+		// Claims are validated by the adapter itself
+		// in order to support leeway, issuer and audience checks
+		SkipClaimsValidation: true,
+	}
+	for _, alg := range algs {
+		parser.ValidMethods = append(parser.ValidMethods, alg.Alg())
+	}
 	return func(h http.HandlerFunc) http.HandlerFunc {
 		return func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
-			ah := r.Header.Get("Authorization")
-			if ah == "" {
-				respond.WithJSON(
-					w, r,
-					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("no authorization header found")),
-				)
+			token, terr := cfg.token(r)
+			if terr != nil {
+				if cfg.optional && terr.Code == "" {
+					h(ctx, w, r)
+					return
+				}
+				cfg.onError(w, r, terr)
 				return
 			}
 
-			s := strings.Split(ah, " ")
-			if len(s) < 2 || s[1] == "" {
-				respond.WithJSON(
-					w, r,
-					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("no bearer token found")),
-				)
+			claims, err := verifyJWTToken(ctx, parser, keys, token)
+			if err != nil {
+				cfg.onError(w, r, &JWTError{Status: gohttp.StatusUnauthorized, Code: "invalid_token", Err: err})
 				return
 			}
 
-			token := s[1]
-
-			claims, err := verifyJWTToken(alg, token, key)
-			if err != nil {
-				respond.WithJSON(w, r, http.NewError(gohttp.StatusUnauthorized, err))
+			if err := cfg.validate(claims); err != nil {
+				cfg.onError(w, r, &JWTError{Status: gohttp.StatusUnauthorized, Code: "invalid_token", Err: err})
 				return
 			}
 
-			if err := callback(context.Background(), token, claims); err != nil {
-				respond.WithJSON(
-					w, r,
-					http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("unauthorized: %v", err)),
-				)
-				return
+			if callback != nil {
+				if err := callback(ctx, token, claims); err != nil {
+					cfg.onError(w, r, &JWTError{
+						Status: gohttp.StatusUnauthorized,
+						Code:   "invalid_token",
+						Err:    fmt.Errorf("unauthorized: %v", err),
+					})
+					return
+				}
+			}
+
+			if cfg.decodeClaims != nil {
+				typed, err := cfg.decodeClaims(claims)
+				if err != nil {
+					cfg.onError(w, r, &JWTError{
+						Status: gohttp.StatusUnauthorized,
+						Code:   "invalid_token",
+						Err:    fmt.Errorf("invalid token claims: %v", err),
+					})
+					return
+				}
+				ctx = context.WithValue(ctx, http.ContextKey(JWTTypedClaimsKey), typed)
 			}
 
-			h(context.WithValue(ctx, http.ContextKey(JWTTokenKey), token), w, r)
+			sub, _ := claims["sub"].(string)
+
+			ctx = context.WithValue(ctx, http.ContextKey(JWTTokenKey), token)
+			ctx = context.WithValue(ctx, http.ContextKey(JWTClaimsKey), claims)
+
+			h(withPrincipal(ctx, &Principal{ID: sub, Scheme: "jwt", Claims: claims}), w, r)
 		}
 	}
 }
 
-func verifyJWTToken(alg JWTAlg, token string, key []byte) (map[string]interface{}, error) {
-	t, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		// if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		if token.Method.Alg() != alg.Name {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return key, nil
+func verifyJWTToken(ctx context.Context, p *jwt.Parser, keys JWTKeySource, token string) (map[string]interface{}, error) {
+	t, err := p.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.JWTKey(ctx, kid, token.Method.Alg())
 	})
 
 	if err != nil {
@@ -102,3 +191,59 @@ func verifyJWTToken(alg JWTAlg, token string, key []byte) (map[string]interface{
 
 	return nil, fmt.Errorf("jwt token could not be verified")
 }
+
+// JWTTokenFromCtx returns verified raw jwt token associated with context
+func JWTTokenFromCtx(c context.Context) string {
+	if t, ok := c.Value(http.ContextKey(JWTTokenKey)).(string); ok {
+		return t
+	}
+	return ""
+}
+
+// JWTClaimsFromCtx returns verified jwt claims associated with context
+func JWTClaimsFromCtx(c context.Context) map[string]interface{} {
+	if claims, ok := c.Value(http.ContextKey(JWTClaimsKey)).(map[string]interface{}); ok {
+		return claims
+	}
+	return nil
+}
+
+// JWTClaimsAs returns claims decoded into T associated with context.
+// Adapter needs to be set up with WithJWTClaimsAs[T] for claims to be available.
+func JWTClaimsAs[T any](c context.Context) (*T, bool) {
+	claims, ok := c.Value(http.ContextKey(JWTTypedClaimsKey)).(*T)
+	return claims, ok
+}
+
+// JWTKeys represents static JWTKeySource mapping key ids to keys.
+// If token has no kid, or there is no key with a matching id,
+// the key stored under empty id (if any) is used.
+type JWTKeys map[string]interface{}
+
+// JWTKey returns the key stored under kid
+func (k JWTKeys) JWTKey(ctx context.Context, kid string, alg string) (interface{}, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	if key, ok := k[""]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id: %q", kid)
+}
+
+// ParseJWTKey parses verification key for given alg. For HMAC algs
+// key is used as is, while for asymmetric ones it is parsed
+// as a PEM encoded public key.
+func ParseJWTKey(alg JWTAlg, key []byte) (interface{}, error) {
+	switch alg.(type) {
+	case *jwt.SigningMethodHMAC:
+		return key, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		return jwt.ParseRSAPublicKeyFromPEM(key)
+	case *jwt.SigningMethodECDSA:
+		return jwt.ParseECPublicKeyFromPEM(key)
+	case *SigningMethodEdDSA:
+		return parseEdPublicKeyFromPEM(key)
+	}
+	return nil, fmt.Errorf("unsupported alg: %s", alg.Alg())
+}
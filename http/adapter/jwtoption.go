@@ -0,0 +1,273 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	gohttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// JWTError represents failed jwt authentication
+type JWTError struct {
+	// Status is http status code to respond with
+	Status int
+
+	// Code is RFC 6750 error code (invalid_request, invalid_token...)
+	// It is empty if request carried no token at all
+	Code string
+
+	// Err describes the failure
+	Err error
+}
+
+// Error returns error description
+func (e *JWTError) Error() string { return e.Err.Error() }
+
+// Challenge returns WWW-Authenticate header value for the error
+func (e *JWTError) Challenge(realm string) string {
+	var params []string
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	if e.Code != "" {
+		params = append(params, fmt.Sprintf("error=%q", e.Code))
+		params = append(params, fmt.Sprintf("error_description=%q", e.Err.Error()))
+	}
+	if params == nil {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// JWTErrorFunc is called by jwt auth adapter to respond to failed authentication
+type JWTErrorFunc func(gohttp.ResponseWriter, *gohttp.Request, *JWTError)
+
+type jwtCfg struct {
+	issuers      []string
+	audiences    []string
+	leeway       time.Duration
+	cookie       string
+	query        string
+	optional     bool
+	realm        string
+	onError      JWTErrorFunc
+	decodeClaims func(map[string]interface{}) (interface{}, error)
+}
+
+func (cfg *jwtCfg) writeError(w gohttp.ResponseWriter, r *gohttp.Request, e *JWTError) {
+	w.Header().Set("WWW-Authenticate", e.Challenge(cfg.realm))
+	respond.WithJSON(w, r, http.NewError(e.Status, e.Err))
+}
+
+// token extracts token from Authorization header, or if there is none,
+// from cookie or query param if enabled. Returned error has no Code
+// set if request carries no bearer token at all.
+func (cfg *jwtCfg) token(r *gohttp.Request) (string, *JWTError) {
+	if ah := r.Header.Get("Authorization"); ah != "" {
+		s := strings.SplitN(ah, " ", 2)
+		if !strings.EqualFold(s[0], "Bearer") {
+			return "", &JWTError{Status: gohttp.StatusUnauthorized, Err: fmt.Errorf("no bearer token found")}
+		}
+		if len(s) < 2 || strings.TrimSpace(s[1]) == "" {
+			return "", &JWTError{
+				Status: gohttp.StatusUnauthorized,
+				Code:   "invalid_request",
+				Err:    fmt.Errorf("no bearer token found"),
+			}
+		}
+		return strings.TrimSpace(s[1]), nil
+	}
+
+	if cfg.cookie != "" {
+		if c, err := r.Cookie(cfg.cookie); err == nil && c.Value != "" {
+			return c.Value, nil
+		}
+	}
+
+	if cfg.query != "" {
+		if t := r.URL.Query().Get(cfg.query); t != "" {
+			return t, nil
+		}
+	}
+
+	if cfg.cookie != "" || cfg.query != "" {
+		return "", &JWTError{Status: gohttp.StatusUnauthorized, Err: fmt.Errorf("no token found")}
+	}
+
+	return "", &JWTError{Status: gohttp.StatusUnauthorized, Err: fmt.Errorf("no authorization header found")}
+}
+
+func (cfg *jwtCfg) validate(claims map[string]interface{}) error {
+	now := time.Now()
+
+	exp, err := timeClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !exp.IsZero() && now.After(exp.Add(cfg.leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+
+	nbf, err := timeClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if !nbf.IsZero() && now.Before(nbf.Add(-cfg.leeway)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	iat, err := timeClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if !iat.IsZero() && now.Before(iat.Add(-cfg.leeway)) {
+		return fmt.Errorf("token used before issued")
+	}
+
+	if cfg.issuers != nil {
+		iss, _ := claims["iss"].(string)
+		if !containsString(cfg.issuers, iss) {
+			return fmt.Errorf("invalid token issuer")
+		}
+	}
+
+	if cfg.audiences != nil {
+		var auds []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			auds = []string{aud}
+		case []interface{}:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					auds = append(auds, s)
+				}
+			}
+		}
+
+		valid := false
+		for _, a := range auds {
+			if containsString(cfg.audiences, a) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid token audience")
+		}
+	}
+
+	return nil
+}
+
+func timeClaim(claims map[string]interface{}, name string) (time.Time, error) {
+	var sec float64
+	switch v := claims[name].(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		sec = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s claim", name)
+		}
+		sec = f
+	default:
+		return time.Time{}, fmt.Errorf("invalid %s claim", name)
+	}
+	return time.Unix(int64(sec), 0), nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WithJWTIssuer requires token iss claim to match one of provided issuers
+func WithJWTIssuer(iss ...string) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.issuers = append(cfg.issuers, iss...)
+	}
+}
+
+// WithJWTAudience requires token aud claim to contain one of provided audiences
+func WithJWTAudience(aud ...string) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.audiences = append(cfg.audiences, aud...)
+	}
+}
+
+// WithJWTLeeway sets allowed clock skew used when validating exp, nbf and iat claims
+func WithJWTLeeway(d time.Duration) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.leeway = d
+	}
+}
+
+// WithJWTCookie enables reading token from given cookie
+// if there is no Authorization header
+func WithJWTCookie(name string) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.cookie = name
+	}
+}
+
+// WithJWTQuery enables reading token from given query param
+// if there is no Authorization header
+func WithJWTQuery(param string) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.query = param
+	}
+}
+
+// WithJWTOptional makes authentication optional, so requests
+// carrying no token pass through without claims (and principal) in context.
+// Requests carrying an invalid token are still rejected.
+func WithJWTOptional() JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.optional = true
+	}
+}
+
+// WithJWTRealm sets realm sent with WWW-Authenticate header
+func WithJWTRealm(realm string) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.realm = realm
+	}
+}
+
+// WithJWTErrorHandler sets custom failed authentication handler.
+// By default WWW-Authenticate header is set (see JWTError.Challenge) and
+// error is written using respond package.
+func WithJWTErrorHandler(f JWTErrorFunc) JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.onError = f
+	}
+}
+
+// WithJWTClaimsAs makes the adapter decode verified claims into T (using
+// its json tags) and store it to context so it can be retrieved with JWTClaimsAs[T].
+// Requests with claims that can not be decoded are rejected.
+func WithJWTClaimsAs[T any]() JWTOption {
+	return func(cfg *jwtCfg) {
+		cfg.decodeClaims = func(claims map[string]interface{}) (interface{}, error) {
+			data, err := json.Marshal(claims)
+			if err != nil {
+				return nil, err
+			}
+			var t T
+			if err := json.Unmarshal(data, &t); err != nil {
+				return nil, err
+			}
+			return &t, nil
+		}
+	}
+}
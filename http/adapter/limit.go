@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	gohttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// LimitOption represents concurrency limit option
+type LimitOption func(*limitCfg)
+
+// WithConcurrencyLimit creates a new adapter capping the number of
+// in-flight requests (100 by default). Requests over the limit wait in a
+// queue (of up to 100 requests for up to 1s by default), and are shed
+// with 503 and Retry-After header if the queue is full or they time out.
+//
+// Limit is shared by all handlers adapter wraps, so registering it with
+// http.WithAdapters limits the whole server while svc.Adapt limits a service.
+func WithConcurrencyLimit(opts ...LimitOption) http.Adapter {
+	cfg := limitCfg{
+		limit:      100,
+		queue:      100,
+		wait:       time.Second,
+		retryAfter: time.Second,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	l := newLimiter(&cfg)
+	retryAfter := strconv.Itoa(int(math.Max(1, math.Ceil(cfg.retryAfter.Seconds()))))
+
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			if matchPath(cfg.exemptPaths, r.URL.Path) {
+				h(c, w, r)
+				return
+			}
+
+			if !l.acquire(c) {
+				w.Header().Set("Retry-After", retryAfter)
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusServiceUnavailable, fmt.Errorf("server overloaded, retry later")))
+				return
+			}
+
+			start := time.Now()
+			defer l.release(start)
+
+			h(c, w, r)
+		}
+	}
+}
+
+type limitCfg struct {
+	limit       int
+	queue       int
+	wait        time.Duration
+	retryAfter  time.Duration
+	exemptPaths []string
+
+	adaptive bool
+	min, max int
+	target   time.Duration
+}
+
+// limiter is a semaphore with a bounded fifo queue, whose
+// limit can be adapted to observed latency (AIMD)
+type limiter struct {
+	cfg *limitCfg
+
+	m        sync.Mutex
+	limit    float64
+	inFlight int
+	waiting  *list.List
+
+	// decreased is when the limit was last decreased, requests
+	// started before it do not decrease it again
+	decreased time.Time
+}
+
+func newLimiter(cfg *limitCfg) *limiter {
+	limit := cfg.limit
+	if cfg.adaptive {
+		if limit > cfg.max {
+			limit = cfg.max
+		}
+		if limit < cfg.min {
+			limit = cfg.min
+		}
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return &limiter{
+		cfg:     cfg,
+		limit:   float64(limit),
+		waiting: list.New(),
+	}
+}
+
+func (l *limiter) acquire(ctx context.Context) bool {
+	l.m.Lock()
+	if l.inFlight < int(l.limit) && l.waiting.Len() == 0 {
+		l.inFlight++
+		l.m.Unlock()
+		return true
+	}
+	if l.waiting.Len() >= l.cfg.queue {
+		l.m.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	elem := l.waiting.PushBack(ready)
+	l.m.Unlock()
+
+	timer := time.NewTimer(l.cfg.wait)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	select {
+	case <-ready:
+		// Slot was granted while timing out
+		return true
+	default:
+		l.waiting.Remove(elem)
+		return false
+	}
+}
+
+func (l *limiter) release(start time.Time) {
+	now := time.Now()
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.inFlight--
+
+	if l.cfg.adaptive {
+		if now.Sub(start) > l.cfg.target {
+			// Decrease at most once per round trip, as requests in flight
+			// at the time already saw latency the decrease reacts to
+			if start.After(l.decreased) {
+				l.limit = math.Max(math.Max(1, float64(l.cfg.min)), l.limit*0.9)
+				l.decreased = now
+			}
+		} else {
+			l.limit = math.Min(float64(l.cfg.max), l.limit+1/l.limit)
+		}
+	}
+
+	for l.inFlight < int(l.limit) && l.waiting.Len() > 0 {
+		close(l.waiting.Remove(l.waiting.Front()).(chan struct{}))
+		l.inFlight++
+	}
+}
+
+func matchPath(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMaxConcurrency sets max number of in-flight requests (100 by default)
+func WithMaxConcurrency(n int) LimitOption {
+	return func(cfg *limitCfg) {
+		cfg.limit = n
+	}
+}
+
+// WithLimitQueue sets max number of requests waiting for a slot and how
+// long they wait for (100 and 1s by default). Size 0 sheds requests
+// over the limit right away.
+func WithLimitQueue(size int, wait time.Duration) LimitOption {
+	return func(cfg *limitCfg) {
+		cfg.queue = size
+		cfg.wait = wait
+	}
+}
+
+// WithAdaptiveLimit makes the limit adapt to observed latency between min
+// and max: it is increased additively while requests complete within
+// target latency, and decreased multiplicatively once they do not (AIMD),
+// at most once per round trip (by requests started after the last decrease).
+// Max concurrency is used as the initial limit.
+func WithAdaptiveLimit(min, max int, target time.Duration) LimitOption {
+	return func(cfg *limitCfg) {
+		cfg.adaptive = true
+		cfg.min = min
+		cfg.max = max
+		cfg.target = target
+	}
+}
+
+// WithLimitRetryAfter sets Retry-After sent with shed requests (1s by default)
+func WithLimitRetryAfter(d time.Duration) LimitOption {
+	return func(cfg *limitCfg) {
+		cfg.retryAfter = d
+	}
+}
+
+// WithLimitExemptPaths sets paths which are never limited, such as health
+// checks and admin routes. Paths ending with * match by prefix.
+func WithLimitExemptPaths(paths ...string) LimitOption {
+	return func(cfg *limitCfg) {
+		cfg.exemptPaths = append(cfg.exemptPaths, paths...)
+	}
+}
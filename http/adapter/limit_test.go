@@ -0,0 +1,214 @@
+package adapter_test
+
+import (
+	"context"
+	gohttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+)
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      []adapter.LimitOption
+		blocked   int
+		path      string
+		release   bool
+		wantCode  int
+		wantRetry string
+	}{
+		{
+			name:      "test shed without queue",
+			opts:      []adapter.LimitOption{adapter.WithMaxConcurrency(1), adapter.WithLimitQueue(0, 0)},
+			blocked:   1,
+			path:      "/orders",
+			wantCode:  503,
+			wantRetry: "1",
+		},
+		{
+			name:     "test exempt path",
+			opts:     []adapter.LimitOption{adapter.WithMaxConcurrency(1), adapter.WithLimitQueue(0, 0), adapter.WithLimitExemptPaths("/health", "/admin/*")},
+			blocked:  1,
+			path:     "/admin/metrics",
+			wantCode: 200,
+		},
+		{
+			name:     "test under limit",
+			opts:     []adapter.LimitOption{adapter.WithMaxConcurrency(2), adapter.WithLimitQueue(0, 0)},
+			blocked:  1,
+			path:     "/orders",
+			wantCode: 200,
+		},
+		{
+			name:     "test queued until slot is released",
+			opts:     []adapter.LimitOption{adapter.WithMaxConcurrency(1), adapter.WithLimitQueue(1, time.Second)},
+			blocked:  1,
+			path:     "/orders",
+			release:  true,
+			wantCode: 200,
+		},
+		{
+			name:      "test queue wait timeout",
+			opts:      []adapter.LimitOption{adapter.WithMaxConcurrency(1), adapter.WithLimitQueue(1, 20*time.Millisecond), adapter.WithLimitRetryAfter(1500 * time.Millisecond)},
+			blocked:   1,
+			path:      "/orders",
+			wantCode:  503,
+			wantRetry: "2",
+		},
+		{
+			name:      "test queue full",
+			opts:      []adapter.LimitOption{adapter.WithMaxConcurrency(1), adapter.WithLimitQueue(1, time.Second)},
+			blocked:   2,
+			path:      "/orders",
+			wantCode:  503,
+			wantRetry: "1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			block := make(chan struct{})
+			started := make(chan struct{}, 10)
+
+			hdlr := adapter.WithConcurrencyLimit(c.opts...)(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				if r.Header.Get("X-Block") != "" {
+					started <- struct{}{}
+					<-block
+				}
+				respond.WithJSON(w, r, "ok")
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < c.blocked; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest("GET", "/orders", nil)
+					req.Header.Set("X-Block", "true")
+					hdlr(context.Background(), httptest.NewRecorder(), req)
+				}()
+			}
+			<-started
+			// Let other blocked requests queue up
+			time.Sleep(10 * time.Millisecond)
+
+			if c.release {
+				time.AfterFunc(10*time.Millisecond, func() { close(block) })
+			}
+
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, httptest.NewRequest("GET", c.path, nil))
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.wantRetry, w.Header().Get("Retry-After"))
+
+			if !c.release {
+				close(block)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestWithConcurrencyLimit_Adaptive(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	hdlr := adapter.WithConcurrencyLimit(
+		adapter.WithMaxConcurrency(4),
+		adapter.WithLimitQueue(0, 0),
+		adapter.WithAdaptiveLimit(1, 4, time.Millisecond),
+	)(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(2 * time.Millisecond)
+		case "/block":
+			close(started)
+			<-block
+		}
+		respond.WithJSON(w, r, http.NewResponse("ok", 200))
+	})
+
+	// Slow responses shrink the limit down to min
+	for i := 0; i < 20; i++ {
+		hdlr(context.Background(), httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}
+
+	go hdlr(context.Background(), httptest.NewRecorder(), httptest.NewRequest("GET", "/block", nil))
+	<-started
+
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 503, w.Code)
+
+	close(block)
+}
+
+func TestWithConcurrencyLimit_AdaptiveDecreaseOncePerRoundTrip(t *testing.T) {
+	var (
+		slow    sync.WaitGroup
+		started = make(chan struct{})
+		block   = make(chan struct{})
+	)
+
+	hdlr := adapter.WithConcurrencyLimit(
+		adapter.WithMaxConcurrency(4),
+		adapter.WithLimitQueue(0, 0),
+		adapter.WithAdaptiveLimit(1, 4, time.Millisecond),
+	)(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			slow.Done()
+			slow.Wait()
+			time.Sleep(2 * time.Millisecond)
+		case "/block":
+			started <- struct{}{}
+			<-block
+		}
+		respond.WithJSON(w, r, http.NewResponse("ok", 200))
+	})
+
+	// Slow requests in flight together decrease the limit only once (4 * 0.9)
+	var wg sync.WaitGroup
+	slow.Add(4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hdlr(context.Background(), httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		}()
+	}
+	wg.Wait()
+
+	rejected := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			hdlr(context.Background(), w, httptest.NewRequest("GET", "/block", nil))
+			if w.Code != 200 {
+				rejected <- w.Code
+			}
+		}()
+		select {
+		case <-started:
+		case code := <-rejected:
+			t.Fatalf("request %d within decreased limit of 3 got %d", i+1, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 503, w.Code)
+
+	close(block)
+	wg.Wait()
+}
@@ -0,0 +1,279 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	gohttp "net/http"
+	"strings"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+)
+
+// ClientIPKey is used to store resolved client ip to context
+const ClientIPKey = "tonto_http_client_ip_key"
+
+// PrivateCIDRs lists loopback and private network ranges load
+// balancers usually run in, to be used with WithTrustedProxies
+var PrivateCIDRs = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+}
+
+// RealIPOption represents real ip option
+type RealIPOption func(*realIPCfg)
+
+// WithRealIP creates a new adapter resolving real client ip, which is
+// stored to context (see ClientIPFromCtx) and used by request logger.
+//
+// Header names the single header the proxies in front of the service set
+// (eg. Forwarded, X-Forwarded-For, X-Real-IP), as any other forwarding
+// header is sent by the client as it is. The header is only trusted if
+// the request comes from one of the proxies set with WithTrustedProxies,
+// in which case the forwarding chain is walked from the right, skipping
+// trusted proxies, and the first address not trusted is the client.
+// Scheme and host are also taken from the hop the client was found at
+// (proto and host of Forwarded header, or X-Forwarded-Proto and
+// X-Forwarded-Host entries at the same position from the right), and set
+// on the request, so that absolute urls built for redirects point to the
+// public address.
+func WithRealIP(header string, opts ...RealIPOption) http.Adapter {
+	cfg := realIPCfg{header: gohttp.CanonicalHeaderKey(header)}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			peer := remoteIP(r)
+			ip := peer
+
+			if peer != nil && cfg.trusted(peer) {
+				var hop forwardedHop
+				ip, hop = cfg.client(peer, cfg.forwarded(r))
+
+				u := *r.URL
+				r = r.WithContext(c)
+				r.URL = &u
+				if hop.proto != "" {
+					r.URL.Scheme = hop.proto
+				}
+				if hop.host != "" {
+					r.Host = hop.host
+					r.URL.Host = hop.host
+				}
+			}
+
+			if ip == nil {
+				h(c, w, r)
+				return
+			}
+
+			c = context.WithValue(c, http.ContextKey(ClientIPKey), ip)
+			h(c, w, r.WithContext(c))
+		}
+	}
+}
+
+// ClientIPFromCtx returns client ip resolved by WithRealIP
+// or nil if none was resolved
+func ClientIPFromCtx(c context.Context) net.IP {
+	if ip, ok := c.Value(http.ContextKey(ClientIPKey)).(net.IP); ok {
+		return ip
+	}
+	return nil
+}
+
+// WithIPAllowList creates a new adapter responding with 403 to requests
+// from client ips outside of provided CIDRs (or plain ips), eg. for
+// internal only services. Use with WithRealIP when running behind proxies.
+// It panics if any of the CIDRs is invalid.
+func WithIPAllowList(cidrs ...string) http.Adapter {
+	nets := mustParseCIDRs(cidrs)
+	return ipFilter(func(ip net.IP) bool { return containsIP(nets, ip) })
+}
+
+// WithIPDenyList creates a new adapter responding with 403 to requests
+// from client ips within provided CIDRs (or plain ips).
+// It panics if any of the CIDRs is invalid.
+func WithIPDenyList(cidrs ...string) http.Adapter {
+	nets := mustParseCIDRs(cidrs)
+	return ipFilter(func(ip net.IP) bool { return !containsIP(nets, ip) })
+}
+
+func ipFilter(allow func(net.IP) bool) http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			ip := ClientIPFromCtx(c)
+			if ip == nil {
+				ip = remoteIP(r)
+			}
+			if ip == nil || !allow(ip) {
+				respond.WithJSON(w, r, http.NewError(gohttp.StatusForbidden, fmt.Errorf("forbidden: client ip not allowed")))
+				return
+			}
+			h(c, w, r)
+		}
+	}
+}
+
+type realIPCfg struct {
+	proxies []*net.IPNet
+	header  string
+}
+
+func (cfg *realIPCfg) trusted(ip net.IP) bool { return containsIP(cfg.proxies, ip) }
+
+// forwardedHop represents forwarding chain hop along with
+// proto and host the request was received with by the proxy
+type forwardedHop struct {
+	addr  string
+	proto string
+	host  string
+}
+
+// forwarded reads forwarding chain from the configured header
+func (cfg *realIPCfg) forwarded(r *gohttp.Request) []forwardedHop {
+	values := r.Header.Values(cfg.header)
+	if len(values) == 0 {
+		return nil
+	}
+
+	if cfg.header == "Forwarded" {
+		return parseForwarded(values)
+	}
+
+	addrs := headerList(values)
+	protos := headerList(r.Header.Values("X-Forwarded-Proto"))
+	hosts := headerList(r.Header.Values("X-Forwarded-Host"))
+
+	// Proxies append to each of the headers, so entries
+	// of the same hop are aligned from the right
+	hops := make([]forwardedHop, len(addrs))
+	for i := range addrs {
+		hops[i].addr = addrs[i]
+		if j := len(protos) - len(addrs) + i; j >= 0 {
+			hops[i].proto = protos[j]
+		}
+		if j := len(hosts) - len(addrs) + i; j >= 0 {
+			hops[i].host = hosts[j]
+		}
+	}
+	return hops
+}
+
+// client walks the chain from the right skipping trusted proxies, returning
+// client ip along with the hop it was found at. Invalid hop ends the walk,
+// in which case the last trusted one is the client.
+func (cfg *realIPCfg) client(peer net.IP, hops []forwardedHop) (net.IP, forwardedHop) {
+	ip, at := peer, forwardedHop{}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHop(hops[i].addr)
+		if hop == nil {
+			break
+		}
+		ip, at = hop, hops[i]
+		if !cfg.trusted(hop) {
+			break
+		}
+	}
+
+	at.proto = strings.ToLower(at.proto)
+	if at.proto != "http" && at.proto != "https" {
+		at.proto = ""
+	}
+
+	return ip, at
+}
+
+// parseForwarded parses RFC 7239 Forwarded header values
+func parseForwarded(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, elem := range headerList(values) {
+		var hop forwardedHop
+		for _, pair := range strings.Split(elem, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			val := strings.Trim(kv[1], `"`)
+			switch strings.ToLower(kv[0]) {
+			case "for":
+				hop.addr = val
+			case "proto":
+				hop.proto = val
+			case "host":
+				hop.host = val
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// headerList splits comma separated header values
+func headerList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(e))
+		}
+	}
+	return list
+}
+
+// parseHop parses forwarding chain hop, which can carry a port
+// and be bracketed (eg. "[2001:db8::1]:4711", "192.0.2.43:47011")
+func parseHop(hop string) net.IP {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+func remoteIP(r *gohttp.Request) net.IP {
+	return parseHop(r.RemoteAddr)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		s := cidr
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(fmt.Sprintf("adapter: invalid CIDR %q: %v", cidr, err))
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// WithTrustedProxies sets CIDRs (or plain ips) of proxies whose forwarding
+// headers are trusted, eg. adapter.PrivateCIDRs. No proxies are trusted
+// by default. It panics if any of the CIDRs is invalid.
+func WithTrustedProxies(cidrs ...string) RealIPOption {
+	nets := mustParseCIDRs(cidrs)
+	return func(cfg *realIPCfg) {
+		cfg.proxies = append(cfg.proxies, nets...)
+	}
+}
@@ -0,0 +1,288 @@
+package adapter_test
+
+import (
+	"context"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+)
+
+func TestWithRealIP(t *testing.T) {
+	cases := []struct {
+		name       string
+		header     string
+		opts       []adapter.RealIPOption
+		remoteAddr string
+		headers    map[string]string
+		wantIP     string
+		wantScheme string
+		wantHost   string
+	}{
+		{
+			name:       "test no proxy",
+			header:     "X-Forwarded-For",
+			remoteAddr: "203.0.113.7:51234",
+			wantIP:     "203.0.113.7",
+			wantHost:   "api.foobar.com",
+		},
+		{
+			name:       "test untrusted peer headers ignored",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "203.0.113.7:51234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.2.3.4",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.com",
+			},
+			wantIP:   "203.0.113.7",
+			wantHost: "api.foobar.com",
+		},
+		{
+			name:       "test x-forwarded-for",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies(adapter.PrivateCIDRs...)},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1, 203.0.113.7, 10.0.0.5",
+				"X-Forwarded-Proto": "http, https, http",
+				"X-Forwarded-Host":  "evil.com, www.foobar.com, internal",
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "www.foobar.com",
+		},
+		{
+			name:       "test all hops trusted",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies(adapter.PrivateCIDRs...)},
+			remoteAddr: "10.0.0.2:51234",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.20, 10.0.0.5"},
+			wantIP:     "192.168.1.20",
+			wantHost:   "api.foobar.com",
+		},
+		{
+			name:       "test invalid hop",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7, garbage, 10.0.0.5"},
+			wantIP:     "10.0.0.5",
+			wantHost:   "api.foobar.com",
+		},
+		{
+			name:       "test forwarded",
+			header:     "Forwarded",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.2")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8:cafe::17]:4711";proto=https;host=www.foobar.com, for=10.0.0.2`,
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			wantIP:     "2001:db8:cafe::17",
+			wantScheme: "https",
+			wantHost:   "www.foobar.com",
+		},
+		{
+			name:       "test x-real-ip",
+			header:     "X-Real-IP",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.7"},
+			wantIP:     "203.0.113.7",
+			wantHost:   "api.foobar.com",
+		},
+		{
+			name:       "test custom header",
+			header:     "CF-Connecting-IP",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"CF-Connecting-IP": "203.0.113.7",
+				"X-Forwarded-For":  "1.2.3.4",
+			},
+			wantIP:   "203.0.113.7",
+			wantHost: "api.foobar.com",
+		},
+		{
+			name:       "test invalid proto ignored",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "javascript",
+			},
+			wantIP:   "203.0.113.7",
+			wantHost: "api.foobar.com",
+		},
+		{
+			name:       "test other headers ignored",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"Forwarded":       "for=10.1.2.3",
+				"X-Real-IP":       "10.1.2.3",
+				"X-Forwarded-For": "203.0.113.7",
+			},
+			wantIP:   "203.0.113.7",
+			wantHost: "api.foobar.com",
+		},
+		{
+			name:       "test spoofed leftmost forwarded host",
+			header:     "Forwarded",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"Forwarded": "for=1.2.3.4;proto=http;host=evil.com, for=203.0.113.7;proto=https;host=www.foobar.com",
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "www.foobar.com",
+		},
+		{
+			name:       "test spoofed leftmost x-forwarded host",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.2.3.4, 203.0.113.7",
+				"X-Forwarded-Proto": "http, https",
+				"X-Forwarded-Host":  "evil.com, www.foobar.com",
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "www.foobar.com",
+		},
+		{
+			name:       "test spoofed host without matching hop",
+			header:     "X-Forwarded-For",
+			opts:       []adapter.RealIPOption{adapter.WithTrustedProxies("10.0.0.0/8")},
+			remoteAddr: "10.0.0.2:51234",
+			headers: map[string]string{
+				"X-Forwarded-For":  "203.0.113.7, 10.0.0.5",
+				"X-Forwarded-Host": "evil.com",
+			},
+			wantIP:   "203.0.113.7",
+			wantHost: "api.foobar.com",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				ip     string
+				scheme string
+				host   string
+			)
+
+			hdlr := adapter.WithRealIP(c.header, c.opts...)(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				ip = adapter.ClientIPFromCtx(ctx).String()
+				scheme = r.URL.Scheme
+				host = r.Host
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = "api.foobar.com"
+			req.RemoteAddr = c.remoteAddr
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+
+			hdlr(context.Background(), httptest.NewRecorder(), req)
+
+			assert.Equal(t, c.wantIP, ip)
+			assert.Equal(t, c.wantScheme, scheme)
+			assert.Equal(t, c.wantHost, host)
+		})
+	}
+}
+
+func TestIPLists(t *testing.T) {
+	cases := []struct {
+		name        string
+		list        string
+		cidrs       []string
+		remoteAddr  string
+		realIP      bool
+		wantCode    int
+		wantHandled bool
+	}{
+		{
+			name:        "test allowed",
+			list:        "allow",
+			cidrs:       []string{"10.0.0.0/8", "192.168.1.1"},
+			remoteAddr:  "192.168.1.1:1234",
+			wantCode:    200,
+			wantHandled: true,
+		},
+		{
+			name:       "test not allowed",
+			list:       "allow",
+			cidrs:      []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.7:1234",
+			wantCode:   403,
+		},
+		{
+			name:       "test not allowed behind proxy",
+			list:       "allow",
+			cidrs:      []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.2:1234",
+			realIP:     true,
+			wantCode:   403,
+		},
+		{
+			name:       "test denied",
+			list:       "deny",
+			cidrs:      []string{"203.0.113.0/24"},
+			remoteAddr: "10.0.0.2:1234",
+			realIP:     true,
+			wantCode:   403,
+		},
+		{
+			name:        "test not denied",
+			list:        "deny",
+			cidrs:       []string{"203.0.113.0/24"},
+			remoteAddr:  "10.0.0.2:1234",
+			wantCode:    200,
+			wantHandled: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handled := false
+
+			hdlr := func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				handled = true
+			}
+
+			if c.list == "allow" {
+				hdlr = adapter.WithIPAllowList(c.cidrs...)(hdlr)
+			} else {
+				hdlr = adapter.WithIPDenyList(c.cidrs...)(hdlr)
+			}
+			if c.realIP {
+				hdlr = adapter.WithRealIP("X-Forwarded-For", adapter.WithTrustedProxies("10.0.0.0/8"))(hdlr)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			w := httptest.NewRecorder()
+
+			hdlr(context.Background(), w, req)
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.wantHandled, handled)
+		})
+	}
+}
+
+func TestWithIPAllowList_InvalidCIDR(t *testing.T) {
+	assert.Panics(t, func() { adapter.WithIPAllowList("10.0.0.0/33") })
+}
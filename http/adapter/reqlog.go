@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
 )
 
 type logMessage struct {
@@ -17,10 +18,13 @@ type logMessage struct {
 	Method     string  `json:"method"`
 	Path       string  `json:"path"`
 	Took       string  `json:"took"`
+	TraceID    string  `json:"trace_id,omitempty"`
 	Body       *string `json:"body,omitempty"`
 }
 
-// WithRequestLogger creates a new request logging adapter
+// WithRequestLogger creates a new request logging adapter.
+// Log lines include trace id of traced requests, and client ip
+// resolved by WithRealIP (which needs to run first) as remote addr.
 func WithRequestLogger(l *log.Logger, logRequestBody bool) http.Adapter {
 	return func(h http.HandlerFunc) http.HandlerFunc {
 		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
@@ -28,6 +32,10 @@ func WithRequestLogger(l *log.Logger, logRequestBody bool) http.Adapter {
 				RemoteAddr: r.RemoteAddr,
 				Method:     r.Method,
 				Path:       r.URL.Path,
+				TraceID:    respond.TraceIDFromCtx(c),
+			}
+			if ip := ClientIPFromCtx(c); ip != nil {
+				msg.RemoteAddr = ip.String()
 			}
 
 			if logRequestBody {
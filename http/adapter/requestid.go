@@ -0,0 +1,38 @@
+package adapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	gohttp "net/http"
+
+	"github.com/tonto/kit/http"
+)
+
+// RequestIDHeader is the header request id is read from and written to
+const RequestIDHeader = "X-Request-Id"
+
+// WithRequestID creates a new request id adapter. Request id is taken
+// from X-Request-Id header or generated if there is none, and is
+// stored to context (see http.RequestIDFromCtx) and set on the response.
+func WithRequestID() http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > 128 {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			c = context.WithValue(c, http.ContextKey(http.RequestIDKey), id)
+			h(c, w, r.WithContext(c))
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,33 @@
+package adapter_test
+
+import (
+	"context"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var got string
+	hdlr := adapter.WithRequestID()(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		got = http.RequestIDFromCtx(ctx)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, req)
+
+	assert.Equal(t, "req-1", got)
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-Id"))
+
+	w = httptest.NewRecorder()
+	hdlr(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+
+	assert.Len(t, got, 32)
+	assert.Equal(t, got, w.Header().Get("X-Request-Id"))
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Bind sets fields of the struct v points to from path variables, query
+// params, headers and cookies, according to field tags, eg:
+//
+//	type listReq struct {
+//		Tenant  string    `header:"X-Tenant"`
+//		Session string    `cookie:"sid"`
+//		UserID  int64     `path:"id"`
+//		Page    int       `query:"page"`
+//		Tags    []string  `query:"tag"`
+//		Since   time.Time `query:"since"`
+//	}
+//
+// Strings, numbers, bools, time.Duration, encoding.TextUnmarshaler
+// implementations (such as time.Time, in RFC 3339 format), pointers and
+// slices of those are supported. Slices are bound from repeated values.
+// Absent values leave fields untouched, and conversion failures are
+// returned as *Error with 400 status naming the offending value.
+//
+// Endpoints registered with RegisterEndpoint have their requests bound
+// after the body is decoded.
+func Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := bindFields(rv.Elem().Type(), bindSources...)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var query map[string][]string
+
+	for _, f := range fields {
+		var values []string
+
+		switch f.source {
+		case "path":
+			if v, ok := mux.Vars(r)[f.name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			if query == nil {
+				query = r.URL.Query()
+			}
+			values = query[f.name]
+		case "header":
+			values = r.Header.Values(f.name)
+		case "cookie":
+			if c, err := r.Cookie(f.name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setValues(rv.Elem().FieldByIndex(f.index), values); err != nil {
+			return NewError(http.StatusBadRequest, fmt.Errorf("invalid %s parameter %q: %v", f.source, f.name, err))
+		}
+	}
+
+	return nil
+}
+
+var bindSources = []string{"path", "query", "header", "cookie"}
+
+type bindField struct {
+	index  []int
+	source string
+	name   string
+}
+
+type bindKey struct {
+	t       reflect.Type
+	sources string
+}
+
+var bindCache sync.Map
+
+// bindFields returns fields of struct type t (including ones of embedded
+// structs) tagged with any of the sources, caching them per type
+func bindFields(t reflect.Type, sources ...string) []bindField {
+	key := bindKey{t, strings.Join(sources, ",")}
+	if fields, ok := bindCache.Load(key); ok {
+		return fields.([]bindField)
+	}
+
+	var fields []bindField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for _, f := range bindFields(sf.Type, sources...) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		for _, src := range sources {
+			name, ok := sf.Tag.Lookup(src)
+			if !ok {
+				continue
+			}
+			if name = strings.Split(name, ",")[0]; name != "" && name != "-" {
+				fields = append(fields, bindField{index: []int{i}, source: src, name: name})
+			}
+			break
+		}
+	}
+
+	bindCache.Store(key, fields)
+
+	return fields
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, val := range values {
+			if err := setValue(s.Index(i), val); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("cannot parse %q as %s: %v", s, v.Type(), err)
+		}
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as duration", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as bool", s)
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as %s", s, v.Kind())
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as %s", s, v.Kind())
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as %s", s, v.Kind())
+		}
+		v.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return json.Unmarshal(b, (*int)(l))
+	}
+	return nil
+}
+
+type paging struct {
+	Page  int  `query:"page"`
+	Limit uint `query:"limit"`
+}
+
+type bindReq struct {
+	paging
+
+	ID      int64         `path:"id"`
+	Tenant  string        `header:"X-Tenant"`
+	Session string        `cookie:"sid"`
+	Tags    []string      `query:"tag"`
+	IDs     []int         `query:"ids"`
+	Active  *bool         `query:"active"`
+	Score   float64       `query:"score"`
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout"`
+	Level   level         `query:"level"`
+	Name    string        `json:"name"`
+	Ignored string        `query:"-"`
+}
+
+func TestBind(t *testing.T) {
+	active := true
+	since, _ := time.Parse(time.RFC3339, "2024-03-01T10:00:00Z")
+
+	cases := []struct {
+		name    string
+		vars    map[string]string
+		query   string
+		headers map[string]string
+		cookie  string
+		want    bindReq
+		wantErr string
+	}{
+		{
+			name:    "test all sources",
+			vars:    map[string]string{"id": "42"},
+			query:   "page=2&limit=10&tag=a&tag=b&ids=1&ids=2&active=true&score=4.5&since=2024-03-01T10:00:00Z&timeout=1m30s&level=high",
+			headers: map[string]string{"X-Tenant": "acme"},
+			cookie:  "s3cr3t",
+			want: bindReq{
+				paging:  paging{Page: 2, Limit: 10},
+				ID:      42,
+				Tenant:  "acme",
+				Session: "s3cr3t",
+				Tags:    []string{"a", "b"},
+				IDs:     []int{1, 2},
+				Active:  &active,
+				Score:   4.5,
+				Since:   since,
+				Timeout: 90 * time.Second,
+				Level:   2,
+			},
+		},
+		{
+			name: "test absent values keep fields",
+			want: bindReq{Name: "keep"},
+		},
+		{
+			name:    "test invalid int",
+			vars:    map[string]string{"id": "abc"},
+			wantErr: `invalid path parameter "id": cannot parse "abc" as int64`,
+		},
+		{
+			name:    "test invalid slice element",
+			query:   "ids=1&ids=x",
+			wantErr: `invalid query parameter "ids": cannot parse "x" as int`,
+		},
+		{
+			name:    "test negative uint",
+			query:   "limit=-1",
+			wantErr: `invalid query parameter "limit": cannot parse "-1" as uint`,
+		},
+		{
+			name:    "test invalid bool",
+			query:   "active=maybe",
+			wantErr: `invalid query parameter "active": cannot parse "maybe" as bool`,
+		},
+		{
+			name:    "test invalid time",
+			query:   "since=yesterday",
+			wantErr: `invalid query parameter "since": cannot parse "yesterday" as time.Time`,
+		},
+		{
+			name:    "test invalid text unmarshaler",
+			query:   "level=medium",
+			wantErr: `invalid query parameter "level": cannot parse "medium" as http_test.level`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?"+c.query+"&-=x", nil)
+			r = mux.SetURLVars(r, c.vars)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			if c.cookie != "" {
+				r.AddCookie(&gohttp.Cookie{Name: "sid", Value: c.cookie})
+			}
+
+			req := bindReq{Name: "keep"}
+			err := http.Bind(r, &req)
+
+			if c.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.True(t, strings.HasPrefix(err.Error(), c.wantErr), err.Error())
+					assert.Equal(t, 400, err.(*http.Error).Code())
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			c.want.Name = "keep"
+			assert.Equal(t, c.want, req)
+		})
+	}
+}
+
+type bindSvc struct {
+	http.BaseService
+}
+
+func (s *bindSvc) Prefix() string { return "customers" }
+
+func TestRegisterEndpoint_Bind(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	type updateReq struct {
+		ID     int64  `path:"id"`
+		Notify bool   `query:"notify"`
+		Name   string `json:"name"`
+	}
+
+	var got *updateReq
+
+	svc := bindSvc{}
+	svc.MustRegisterEndpoint("PUT", "/{id}", func(c context.Context, w gohttp.ResponseWriter, req *updateReq) error {
+		got = req
+		return nil
+	})
+	s.MustRegisterService(&svc)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/customers/7?notify=true", strings.NewReader(`{"name":"John"}`)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, &updateReq{ID: 7, Notify: true, Name: "John"}, got)
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/customers/7?notify=often", strings.NewReader(`{"name":"John"}`)))
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), `invalid query parameter \"notify\"`)
+}
@@ -0,0 +1,34 @@
+package http
+
+// Chain represents an ordered list of adapters, listed from the
+// outermost (first to see the request) to the innermost one.
+//
+// Note that it is the reverse of AdaptHandlerFunc (and WithAdapters,
+// BaseService.Adapt), where the last adapter is the outermost.
+type Chain []Adapter
+
+// NewChain creates new chain of adapters listed from outermost to innermost
+func NewChain(adapters ...Adapter) Chain {
+	return append(Chain(nil), adapters...)
+}
+
+// Append returns new chain with adapters added as innermost ones
+func (c Chain) Append(adapters ...Adapter) Chain {
+	return append(append(Chain(nil), c...), adapters...)
+}
+
+// Then adapts hf with chain adapters, so that the first one is outermost
+func (c Chain) Then(hf HandlerFunc) HandlerFunc {
+	for i := len(c) - 1; i >= 0; i-- {
+		hf = c[i](hf)
+	}
+	return hf
+}
+
+// Adapter returns chain as a single adapter, so that it can be used
+// anywhere an adapter is accepted, eg:
+//
+//	http.WithAdapters(http.NewChain(logger, auth, limit).Adapter())
+func (c Chain) Adapter() Adapter {
+	return c.Then
+}
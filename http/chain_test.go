@@ -0,0 +1,61 @@
+package http_test
+
+import (
+	"context"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+
+	named := func(name string) http.Adapter {
+		return func(h http.HandlerFunc) http.HandlerFunc {
+			return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+				order = append(order, name)
+				h(c, w, r)
+			}
+		}
+	}
+
+	hf := func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		order = append(order, "handler")
+	}
+
+	chain := http.NewChain(named("outer"), named("middle"))
+	inner := chain.Append(named("inner"))
+
+	cases := []struct {
+		name string
+		hf   http.HandlerFunc
+		want []string
+	}{
+		{
+			name: "test then",
+			hf:   inner.Then(hf),
+			want: []string{"outer", "middle", "inner", "handler"},
+		},
+		{
+			name: "test append does not modify chain",
+			hf:   chain.Then(hf),
+			want: []string{"outer", "middle", "handler"},
+		},
+		{
+			name: "test as adapter",
+			hf:   http.AdaptHandlerFunc(hf, inner.Adapter(), named("first")),
+			want: []string{"first", "outer", "middle", "inner", "handler"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order = nil
+			c.hf(context.Background(), httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			assert.Equal(t, c.want, order)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when requests are not sent
+// because the circuit breaker is open
+var ErrCircuitOpen = errors.New("client: circuit breaker is open")
+
+// WithCircuitBreaker enables circuit breaker which opens after given
+// number of consecutive failures (transport errors and 429, 502, 503, 504
+// responses, but not requests canceled by the caller) failing all requests
+// with ErrCircuitOpen. After cooldown a single trial request
+// is let through, closing the circuit on success.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.breaker = &breaker{threshold: failures, cooldown: cooldown}
+	}
+}
+
+type breaker struct {
+	m         sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func (b *breaker) allow() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	// Half open, let a single trial request through
+	if !b.trial && time.Since(b.openedAt) >= b.cooldown {
+		b.trial = true
+		return true
+	}
+
+	return false
+}
+
+// release lets another trial request through if the
+// allowed one was not recorded (eg. it was canceled)
+func (b *breaker) release() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) record(ok bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.trial = false
+
+	if ok {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,254 @@
+// Package client provides a typed http client for calling kit http
+// services, which decodes response envelope data into response types
+// and returns envelope errors as *http.Error
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/tonto/kit/http"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// RequestIDHeader is the header request id found in context is sent with
+const RequestIDHeader = "X-Request-Id"
+
+// Option represents client option
+type Option func(*Client)
+
+// New creates new client calling service at base url, eg. http://orders:8080/orders
+func New(baseURL string, opts ...Option) *Client {
+	c := Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		hc:         &gohttp.Client{Timeout: 30 * time.Second},
+		propagator: propagation.TraceContext{},
+		header:     gohttp.Header{},
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 2 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return &c
+}
+
+// Client represents kit http service client
+type Client struct {
+	baseURL    string
+	hc         *gohttp.Client
+	propagator propagation.TextMapPropagator
+	header     gohttp.Header
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	breaker    *breaker
+}
+
+type envelope struct {
+	Code   int             `json:"code"`
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+// Do sends JSON encoded req (nil for no body) to path and decodes
+// response data into Resp. Envelope errors are returned as *http.Error.
+func Do[Resp any](ctx context.Context, c *Client, method, path string, req interface{}) (*Resp, error) {
+	var body []byte
+	if req != nil {
+		var err error
+		body, err = json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("client: could not encode request: %v", err)
+		}
+	}
+
+	data, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(Resp)
+	if len(data) == 0 || string(data) == "null" {
+		return resp, nil
+	}
+
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, fmt.Errorf("client: could not decode response data: %v", err)
+	}
+
+	return resp, nil
+}
+
+// Get sends GET request to path decoding response data into Resp
+func Get[Resp any](ctx context.Context, c *Client, path string) (*Resp, error) {
+	return Do[Resp](ctx, c, gohttp.MethodGet, path, nil)
+}
+
+// Post sends POST request to path decoding response data into Resp
+func Post[Req, Resp any](ctx context.Context, c *Client, path string, req Req) (*Resp, error) {
+	return Do[Resp](ctx, c, gohttp.MethodPost, path, req)
+}
+
+// Put sends PUT request to path decoding response data into Resp
+func Put[Req, Resp any](ctx context.Context, c *Client, path string, req Req) (*Resp, error) {
+	return Do[Resp](ctx, c, gohttp.MethodPut, path, req)
+}
+
+// Patch sends PATCH request to path decoding response data into Resp
+func Patch[Req, Resp any](ctx context.Context, c *Client, path string, req Req) (*Resp, error) {
+	return Do[Resp](ctx, c, gohttp.MethodPatch, path, req)
+}
+
+// Delete sends DELETE request to path decoding response data into Resp
+func Delete[Resp any](ctx context.Context, c *Client, path string) (*Resp, error) {
+	return Do[Resp](ctx, c, gohttp.MethodDelete, path, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (json.RawMessage, error) {
+	retries := 0
+	if idempotent(ctx, method) {
+		retries = c.retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if c.breaker != nil && !c.breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		data, retryAfter, err := c.send(ctx, method, path, body)
+
+		// Requests canceled by the caller say nothing about
+		// the upstream, so they are neither retried nor recorded
+		if err != nil && ctx.Err() != nil {
+			if c.breaker != nil {
+				c.breaker.release()
+			}
+			return nil, ctx.Err()
+		}
+
+		if c.breaker != nil {
+			c.breaker.record(!retryable(err))
+		}
+
+		if err == nil || !retryable(err) || attempt >= retries {
+			return data, err
+		}
+
+		wait := retryAfter
+		if wait == 0 {
+			wait = c.backoffFor(attempt)
+		}
+		// Don't wait for upstreams asking for more than max backoff
+		if wait > c.maxBackoff {
+			return data, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (json.RawMessage, time.Duration, error) {
+	var rdr io.Reader
+	if body != nil {
+		rdr = bytes.NewReader(body)
+	}
+
+	req, err := gohttp.NewRequest(method, c.baseURL+path, rdr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("client: could not create request: %v", err)
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key := IdempotencyKeyFromCtx(ctx); key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if id := http.RequestIDFromCtx(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	c.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, 0, &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, &transportError{err: err}
+	}
+
+	// Successful responses without body (eg. 204) carry no data
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && len(bytes.TrimSpace(data)) == 0 {
+		return nil, 0, nil
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Code == 0 {
+		if resp.StatusCode < 400 {
+			return nil, 0, fmt.Errorf("client: invalid response envelope")
+		}
+		env = envelope{
+			Code:   resp.StatusCode,
+			Errors: []string{fmt.Sprintf("unexpected response: %s", resp.Status)},
+		}
+	}
+
+	if env.Code >= 400 || resp.StatusCode >= 400 {
+		code := env.Code
+		if code < 400 {
+			code = resp.StatusCode
+		}
+		errs := make([]error, len(env.Errors))
+		for i, e := range env.Errors {
+			errs[i] = errors.New(e)
+		}
+		return nil, retryAfter(resp), http.NewError(code, errs...)
+	}
+
+	return env.Data, 0, nil
+}
+
+// WithHTTPClient sets underlying http client
+func WithHTTPClient(hc *gohttp.Client) Option {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithHeader sets a header sent with every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithPropagator sets propagator used to send trace context
+// found in request context (W3C traceparent by default)
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *Client) {
+		c.propagator = p
+	}
+}
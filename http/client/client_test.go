@@ -0,0 +1,229 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/client"
+	"github.com/tonto/kit/http/respond"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type customer struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type customerSvc struct {
+	http.BaseService
+	failures int32
+	hits     int32
+}
+
+func (s *customerSvc) Prefix() string { return "customer" }
+
+func newServer(t *testing.T) (*customerSvc, *httptest.Server) {
+	svc := customerSvc{}
+
+	svc.MustRegisterEndpoint("POST", "/create", func(c context.Context, w gohttp.ResponseWriter, req *customer) (*http.Response, error) {
+		if atomic.AddInt32(&svc.hits, 1) <= atomic.LoadInt32(&svc.failures) {
+			return nil, http.NewError(gohttp.StatusServiceUnavailable, fmt.Errorf("overloaded"))
+		}
+		return http.NewResponse(req, gohttp.StatusCreated), nil
+	})
+
+	svc.RegisterHandler("GET", "/details", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		if atomic.AddInt32(&svc.hits, 1) <= atomic.LoadInt32(&svc.failures) {
+			respond.WithJSON(w, r, http.NewError(gohttp.StatusServiceUnavailable, fmt.Errorf("overloaded")))
+			return
+		}
+		respond.WithJSON(w, r, customer{ID: 1, Name: "John Doe"})
+	})
+
+	svc.RegisterHandler("GET", "/missing", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		respond.WithJSON(w, r, http.NewError(gohttp.StatusNotFound, fmt.Errorf("customer not found"), fmt.Errorf("try again")))
+	})
+
+	svc.RegisterHandler("GET", "/slow", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		atomic.AddInt32(&svc.hits, 1)
+		<-c.Done()
+	})
+
+	svc.RegisterHandler("DELETE", "/remove", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		w.WriteHeader(gohttp.StatusNoContent)
+	})
+
+	svc.RegisterHandler("GET", "/headers", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		respond.WithJSON(w, r, map[string]string{
+			"request_id":  r.Header.Get("X-Request-Id"),
+			"traceparent": r.Header.Get("traceparent"),
+			"static":      r.Header.Get("X-Static"),
+		})
+	})
+
+	srv := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+	srv.MustRegisterService(&svc)
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	return &svc, ts
+}
+
+func TestClient(t *testing.T) {
+	_, ts := newServer(t)
+	c := client.New(ts.URL + "/customer")
+	ctx := context.Background()
+
+	cust, err := client.Get[customer](ctx, c, "/details")
+	assert.NoError(t, err)
+	assert.Equal(t, &customer{ID: 1, Name: "John Doe"}, cust)
+
+	cust, err = client.Post[customer, customer](ctx, c, "/create", customer{ID: 2, Name: "Jane"})
+	assert.NoError(t, err)
+	assert.Equal(t, &customer{ID: 2, Name: "Jane"}, cust)
+
+	_, err = client.Get[customer](ctx, c, "/missing")
+	herr, ok := err.(*http.Error)
+	assert.True(t, ok)
+	assert.Equal(t, gohttp.StatusNotFound, herr.Code())
+	assert.Equal(t, []error{errors.New("customer not found"), errors.New("try again")}, herr.Errs())
+
+	_, err = client.Get[customer](ctx, c, "/nope")
+	herr, ok = err.(*http.Error)
+	assert.True(t, ok)
+	assert.Equal(t, gohttp.StatusNotFound, herr.Code())
+}
+
+func TestClient_Retries(t *testing.T) {
+	cases := []struct {
+		name     string
+		post     bool
+		key      string
+		failures int32
+		wantHits int32
+		wantErr  bool
+	}{
+		{name: "test get retried", failures: 2, wantHits: 3},
+		{name: "test retries exhausted", failures: 5, wantHits: 4, wantErr: true},
+		{name: "test post not retried", post: true, failures: 2, wantHits: 1, wantErr: true},
+		{name: "test post with idempotency key retried", post: true, key: "k1", failures: 2, wantHits: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, ts := newServer(t)
+			svc.failures = tc.failures
+
+			c := client.New(
+				ts.URL+"/customer",
+				client.WithRetries(3),
+				client.WithBackoff(time.Millisecond, 5*time.Millisecond),
+			)
+
+			ctx := context.Background()
+			if tc.key != "" {
+				ctx = client.WithIdempotencyKey(ctx, tc.key)
+			}
+
+			var err error
+			if tc.post {
+				_, err = client.Post[customer, customer](ctx, c, "/create", customer{ID: 1})
+			} else {
+				_, err = client.Get[customer](ctx, c, "/details")
+			}
+
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantHits, atomic.LoadInt32(&svc.hits))
+		})
+	}
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	svc, ts := newServer(t)
+	svc.failures = 100
+
+	c := client.New(ts.URL+"/customer", client.WithCircuitBreaker(2, 50*time.Millisecond))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get[customer](ctx, c, "/details")
+		assert.IsType(t, &http.Error{}, err)
+	}
+
+	_, err := client.Get[customer](ctx, c, "/details")
+	assert.Equal(t, client.ErrCircuitOpen, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&svc.hits))
+
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&svc.failures, 0)
+
+	_, err = client.Get[customer](ctx, c, "/details")
+	assert.NoError(t, err)
+
+	_, err = client.Get[customer](ctx, c, "/details")
+	assert.NoError(t, err)
+}
+
+func TestClient_Canceled(t *testing.T) {
+	svc, ts := newServer(t)
+
+	c := client.New(
+		ts.URL+"/customer",
+		client.WithRetries(3),
+		client.WithBackoff(time.Millisecond, 5*time.Millisecond),
+		client.WithCircuitBreaker(1, time.Hour),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Get[customer](ctx, c, "/slow")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&svc.hits))
+
+	_, err = client.Get[customer](context.Background(), c, "/details")
+	assert.NoError(t, err)
+}
+
+func TestClient_NoContent(t *testing.T) {
+	_, ts := newServer(t)
+	c := client.New(ts.URL + "/customer")
+
+	cust, err := client.Delete[customer](context.Background(), c, "/remove")
+	assert.NoError(t, err)
+	assert.Equal(t, &customer{}, cust)
+}
+
+func TestClient_Propagation(t *testing.T) {
+	_, ts := newServer(t)
+	c := client.New(ts.URL+"/customer", client.WithHeader("X-Static", "static"))
+
+	tid, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	sid, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := context.WithValue(context.Background(), http.ContextKey(http.RequestIDKey), "req-1")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	headers, err := client.Get[map[string]string](ctx, c, "/headers")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"request_id":  "req-1",
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"static":      "static",
+	}, *headers)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	gohttp "net/http"
+	"strconv"
+	"time"
+
+	"github.com/tonto/kit/http"
+)
+
+// IdempotencyKeyHeader is the header idempotency key found in context is sent with
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type ctxKey string
+
+const idempotencyKey ctxKey = "tonto_http_client_idempotency_key"
+
+// WithIdempotencyKey returns a copy of ctx carrying idempotency key.
+// Requests sent with such context carry Idempotency-Key header and
+// are retried even if their method is not idempotent (eg. POST).
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey, key)
+}
+
+// IdempotencyKeyFromCtx returns idempotency key associated with context
+func IdempotencyKeyFromCtx(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey).(string)
+	return key
+}
+
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return "client: " + e.err.Error() }
+
+func (e *transportError) Unwrap() error { return e.err }
+
+func idempotent(ctx context.Context, method string) bool {
+	switch method {
+	case gohttp.MethodGet, gohttp.MethodHead, gohttp.MethodOptions, gohttp.MethodPut, gohttp.MethodDelete:
+		return true
+	}
+	return IdempotencyKeyFromCtx(ctx) != ""
+}
+
+// retryable reports whether request failed due to transport
+// error or an overloaded / unavailable upstream
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case *transportError:
+		return true
+	case *http.Error:
+		switch e.Code() {
+		case gohttp.StatusTooManyRequests,
+			gohttp.StatusBadGateway,
+			gohttp.StatusServiceUnavailable,
+			gohttp.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// backoffFor returns exponential backoff with full jitter for given attempt
+func (c *Client) backoffFor(attempt int) time.Duration {
+	d := c.backoff << uint(attempt)
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func retryAfter(resp *gohttp.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// WithRetries sets the number of times failed idempotent requests are
+// retried (none by default). Requests are retried upon transport errors
+// and 429, 502, 503 and 504 responses, honoring Retry-After header
+// (requests asked to retry after more than max backoff are not retried).
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// WithBackoff sets base and max retry backoff (100ms and 2s by default)
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = base
+		c.maxBackoff = max
+	}
+}
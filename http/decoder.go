@@ -0,0 +1,191 @@
+package http
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Decoder decodes request body into v (pointer to endpoint request type)
+type Decoder interface {
+	Decode(r *http.Request, v interface{}) error
+}
+
+// DecoderFunc is an adapter allowing use of ordinary funcs as decoders
+type DecoderFunc func(*http.Request, interface{}) error
+
+// Decode calls f(r, v)
+func (f DecoderFunc) Decode(r *http.Request, v interface{}) error { return f(r, v) }
+
+// MaxMultipartMemory is the max size of multipart form parts kept in memory,
+// the rest is stored in temporary files
+const MaxMultipartMemory = 32 << 20
+
+var decoders = struct {
+	sync.RWMutex
+	m map[string]Decoder
+}{
+	m: map[string]Decoder{
+		"application/json":                  DecoderFunc(decodeJSON),
+		"application/x-www-form-urlencoded": DecoderFunc(decodeForm),
+		"multipart/form-data":               DecoderFunc(decodeMultipart),
+		"application/xml":                   DecoderFunc(decodeXML),
+		"text/xml":                          DecoderFunc(decodeXML),
+		"application/msgpack":               DecoderFunc(decodeMsgpack),
+		"application/x-msgpack":             DecoderFunc(decodeMsgpack),
+		"application/vnd.msgpack":           DecoderFunc(decodeMsgpack),
+		"application/protobuf":              DecoderFunc(decodeProtobuf),
+		"application/x-protobuf":            DecoderFunc(decodeProtobuf),
+	},
+}
+
+// RegisterDecoder registers decoder for media type (eg. application/cbor)
+// used by endpoints of all services, replacing any existing one.
+// Use BaseService.RegisterDecoder to register it for a single service.
+func RegisterDecoder(mediaType string, d Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.m[strings.ToLower(mediaType)] = d
+}
+
+// RegisterDecoder registers decoder for media type used by service endpoints,
+// taking precedence over ones registered with http.RegisterDecoder
+func (b *BaseService) RegisterDecoder(mediaType string, d Decoder) {
+	if b.decoders == nil {
+		b.decoders = make(map[string]Decoder)
+	}
+	b.decoders[strings.ToLower(mediaType)] = d
+}
+
+// decoder looks up decoder for request content type. Requests without
+// content type are decoded as JSON, and structured syntax suffixes
+// (eg. application/vnd.api+json) fall back to their base type.
+func (b *BaseService) decoder(r *http.Request) (Decoder, error) {
+	mt := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		mt, _, err = mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, NewError(http.StatusUnsupportedMediaType, fmt.Errorf("invalid content type %q", ct))
+		}
+	}
+
+	candidates := []string{mt}
+	if i := strings.LastIndex(mt, "+"); i != -1 {
+		candidates = append(candidates, "application/"+mt[i+1:])
+	}
+
+	decoders.RLock()
+	defer decoders.RUnlock()
+
+	for _, c := range candidates {
+		if d, ok := b.decoders[c]; ok {
+			return d, nil
+		}
+		if d, ok := decoders.m[c]; ok {
+			return d, nil
+		}
+	}
+
+	return nil, NewError(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", mt))
+}
+
+func decodeXML(r *http.Request, v interface{}) error {
+	if err := xml.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding xml: %v", err)
+	}
+	return nil
+}
+
+func decodeMsgpack(r *http.Request, v interface{}) error {
+	dec := msgpack.NewDecoder(r.Body)
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("error decoding msgpack: %v", err)
+	}
+	return nil
+}
+
+func decodeProtobuf(r *http.Request, v interface{}) error {
+	var m proto.Message
+	switch pm := v.(type) {
+	case proto.Message:
+		m = pm
+	case protoadapt.MessageV1:
+		// Types generated by older github.com/golang/protobuf
+		m = protoadapt.MessageV2Of(pm)
+	default:
+		return NewError(http.StatusUnsupportedMediaType, fmt.Errorf("protobuf is not supported by endpoint"))
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading request: %v", err)
+	}
+	if err := proto.Unmarshal(b, m); err != nil {
+		return fmt.Errorf("error decoding protobuf: %v", err)
+	}
+	return nil
+}
+
+func decodeForm(r *http.Request, v interface{}) error {
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("error decoding form: %v", err)
+	}
+	return bindForm(v, r.PostForm, nil)
+}
+
+func decodeMultipart(r *http.Request, v interface{}) error {
+	if err := r.ParseMultipartForm(MaxMultipartMemory); err != nil {
+		return fmt.Errorf("error decoding multipart form: %v", err)
+	}
+	return bindForm(v, r.MultipartForm.Value, r.MultipartForm.File)
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindForm sets fields tagged with form (or json) tag from form values.
+// Multipart files are set to *multipart.FileHeader or []*multipart.FileHeader fields.
+func bindForm(v interface{}, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("forms can only be decoded into structs")
+	}
+
+	for _, f := range bindFields(rv.Elem().Type(), "form", "json") {
+		fv := rv.Elem().FieldByIndex(f.index)
+
+		switch fv.Type() {
+		case fileHeaderType:
+			if fh := files[f.name]; len(fh) > 0 {
+				fv.Set(reflect.ValueOf(fh[0]))
+			}
+			continue
+		case fileHeadersType:
+			if fh := files[f.name]; len(fh) > 0 {
+				fv.Set(reflect.ValueOf(fh))
+			}
+			continue
+		}
+
+		if vals := values[f.name]; len(vals) > 0 {
+			if err := setValues(fv, vals); err != nil {
+				return NewError(http.StatusBadRequest, fmt.Errorf("invalid form field %q: %v", f.name, err))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type decodeReq struct {
+	Name   string                `json:"name" xml:"name"`
+	Qty    int                   `json:"qty" xml:"qty"`
+	Tags   []string              `form:"tag" json:"tags" xml:"tag"`
+	Avatar *multipart.FileHeader `form:"avatar" json:"-" xml:"-"`
+}
+
+type decodeSvc struct {
+	http.BaseService
+}
+
+func (s *decodeSvc) Prefix() string { return "decode" }
+
+func TestDecoders(t *testing.T) {
+	mp := func() (io.Reader, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("name", "John")
+		mw.WriteField("qty", "3")
+		mw.WriteField("tag", "a")
+		mw.WriteField("tag", "b")
+		fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+		fw.Write([]byte("png"))
+		mw.Close()
+		return &buf, mw.FormDataContentType()
+	}
+
+	msgp := func() (io.Reader, string) {
+		b, _ := msgpack.Marshal(map[string]interface{}{"name": "John", "qty": 3, "tags": []string{"a", "b"}})
+		return bytes.NewReader(b), "application/msgpack"
+	}
+
+	body := func(s, ct string) func() (io.Reader, string) {
+		return func() (io.Reader, string) { return strings.NewReader(s), ct }
+	}
+
+	cases := []struct {
+		name     string
+		body     func() (io.Reader, string)
+		wantCode int
+		wantFile string
+		wantErr  string
+	}{
+		{
+			name:     "test json",
+			body:     body(`{"name":"John","qty":3,"tags":["a","b"]}`, "application/json; charset=utf-8"),
+			wantCode: 200,
+		},
+		{
+			name:     "test no content type",
+			body:     body(`{"name":"John","qty":3,"tags":["a","b"]}`, ""),
+			wantCode: 200,
+		},
+		{
+			name:     "test json suffix",
+			body:     body(`{"name":"John","qty":3,"tags":["a","b"]}`, "application/vnd.orders+json"),
+			wantCode: 200,
+		},
+		{
+			name:     "test form",
+			body:     body(`name=John&qty=3&tag=a&tag=b`, "application/x-www-form-urlencoded"),
+			wantCode: 200,
+		},
+		{
+			name:     "test invalid form field",
+			body:     body(`name=John&qty=three`, "application/x-www-form-urlencoded"),
+			wantCode: 400,
+			wantErr:  `invalid form field \"qty\": cannot parse \"three\" as int`,
+		},
+		{
+			name:     "test multipart",
+			body:     mp,
+			wantCode: 200,
+			wantFile: "avatar.png",
+		},
+		{
+			name:     "test xml",
+			body:     body(`<req><name>John</name><qty>3</qty><tag>a</tag><tag>b</tag></req>`, "application/xml"),
+			wantCode: 200,
+		},
+		{
+			name:     "test msgpack",
+			body:     msgp,
+			wantCode: 200,
+		},
+		{
+			name:     "test service decoder",
+			body:     body("John;3;a|b", "text/csv"),
+			wantCode: 200,
+		},
+		{
+			name:     "test unsupported content type",
+			body:     body("John", "text/plain"),
+			wantCode: 415,
+			wantErr:  `unsupported content type \"text/plain\"`,
+		},
+		{
+			name:     "test protobuf not supported by endpoint",
+			body:     body("John", "application/protobuf"),
+			wantCode: 415,
+		},
+	}
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	var got *decodeReq
+
+	svc := decodeSvc{}
+	svc.RegisterDecoder("text/csv", http.DecoderFunc(func(r *gohttp.Request, v interface{}) error {
+		b, _ := ioutil.ReadAll(r.Body)
+		req := v.(*decodeReq)
+		parts := strings.Split(string(b), ";")
+		req.Name = parts[0]
+		fmt.Sscan(parts[1], &req.Qty)
+		req.Tags = strings.Split(parts[2], "|")
+		return nil
+	}))
+	svc.MustRegisterEndpoint("POST", "/", func(c context.Context, w gohttp.ResponseWriter, req *decodeReq) error {
+		got = req
+		return nil
+	})
+	s.MustRegisterService(&svc)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got = nil
+
+			rdr, ct := c.body()
+			req := httptest.NewRequest("POST", "/decode", rdr)
+			if ct != "" {
+				req.Header.Set("Content-Type", ct)
+			}
+			w := httptest.NewRecorder()
+
+			s.ServeHTTP(w, req)
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), c.wantErr)
+
+			if c.wantCode != 200 {
+				return
+			}
+
+			if assert.NotNil(t, got) {
+				assert.Equal(t, "John", got.Name)
+				assert.Equal(t, 3, got.Qty)
+				assert.Equal(t, []string{"a", "b"}, got.Tags)
+			}
+			if c.wantFile != "" && got != nil {
+				assert.Equal(t, c.wantFile, got.Avatar.Filename)
+			}
+		})
+	}
+}
+
+func TestDecoders_Protobuf(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := decodeSvc{}
+	svc.MustRegisterEndpoint("POST", "/", func(c context.Context, w gohttp.ResponseWriter, req *wrapperspb.StringValue) (*http.Response, error) {
+		return http.NewResponse(req.GetValue(), 200), nil
+	})
+	s.MustRegisterService(&svc)
+
+	b, _ := proto.Marshal(wrapperspb.String("John"))
+	req := httptest.NewRequest("POST", "/decode", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, req)
+
+	var resp struct {
+		Data string `json:"data"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "John", resp.Data)
+}
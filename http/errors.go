@@ -1,5 +1,7 @@
 package http
 
+import "errors"
+
 // NewError wraps provided errs and http response code
 // thus creating new http error
 func NewError(code int, errs ...error) *Error {
@@ -29,3 +31,82 @@ func (e *Error) Error() string {
 	}
 	return str
 }
+
+// NewFieldError creates new error describing invalid request field,
+// identified by its path (eg. items[2].qty) along with the code of the rule
+// it violates (eg. min). It can be returned by Validator, in which case it is
+// responded with 422 status, listed in the fields section of the response.
+func NewFieldError(field string, rule string, message string) *FieldError {
+	return &FieldError{
+		field:   field,
+		rule:    rule,
+		message: message,
+	}
+}
+
+// FieldError represents invalid request field error
+type FieldError struct {
+	field   string
+	rule    string
+	message string
+}
+
+// Field returns path of the invalid field
+func (e *FieldError) Field() string { return e.field }
+
+// Rule returns code of the violated rule
+func (e *FieldError) Rule() string { return e.rule }
+
+// Message returns violation description
+func (e *FieldError) Message() string { return e.message }
+
+// Error returns error description
+func (e *FieldError) Error() string {
+	if e.field == "" {
+		return e.message
+	}
+	return e.field + ": " + e.message
+}
+
+// FieldErrors groups errors of multiple invalid fields
+type FieldErrors []*FieldError
+
+// Error returns error description
+func (e FieldErrors) Error() string {
+	var str string
+	for i, err := range e {
+		if i > 0 {
+			str += "; "
+		}
+		str += err.Error()
+	}
+	return str
+}
+
+// Unwrap returns grouped errors
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+type fieldError interface {
+	Field() string
+	Rule() string
+	Message() string
+}
+
+// fieldErrors returns field errors err consists of, or err itself
+// if it wraps any (the same ones respond lists in fields section)
+func fieldErrors(err error) []error {
+	if fe, ok := err.(FieldErrors); ok && len(fe) > 0 {
+		return fe.Unwrap()
+	}
+	var fe fieldError
+	if errors.As(err, &fe) {
+		return []error{err}
+	}
+	return nil
+}
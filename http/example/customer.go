@@ -5,16 +5,14 @@ import (
 	"fmt"
 	ghttp "net/http"
 
-	"github.com/gorilla/mux"
 	"github.com/tonto/kit/http"
-	"github.com/tonto/kit/http/respond"
 )
 
 // NewCustomerService creates new customer service
-func NewCustomerService(apts ...http.Adapter) *Customer {
+func NewCustomerService(apts ...http.RouteOption) *Customer {
 	svc := Customer{}
 
-	svc.RegisterHandler("GET", "/details/{id}", svc.details)
+	svc.MustRegisterEndpoint("GET", "/details/{id}", svc.details)
 	svc.RegisterEndpoint("PUT", "/update", svc.update)
 
 	svc.MustRegisterEndpoint("POST", "/create", svc.create)
@@ -32,9 +30,12 @@ type Customer struct {
 // Prefix returns service routing prefix
 func (o *Customer) Prefix() string { return "customer" }
 
-func (o *Customer) details(c context.Context, w ghttp.ResponseWriter, r *ghttp.Request) {
-	id := mux.Vars(r)["id"]
-	respond.WithJSON(w, r, http.NewResponse(id, ghttp.StatusOK))
+type customerDetailsReq struct {
+	ID int64 `path:"id"`
+}
+
+func (o *Customer) details(c context.Context, w ghttp.ResponseWriter, req *customerDetailsReq) (*http.Response, error) {
+	return http.NewResponse(req.ID, ghttp.StatusOK), nil
 }
 
 type customerCreateReq struct {
@@ -12,7 +12,7 @@ import (
 )
 
 // NewOrderService creates new order service
-func NewOrderService(apts ...http.Adapter) *Order {
+func NewOrderService(apts ...http.RouteOption) *Order {
 	svc := Order{}
 
 	// Normal handler where you handle request decoding and validation
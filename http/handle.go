@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/tonto/kit/http/respond"
+)
+
+// endpointService is implemented by services embedding BaseService
+type endpointService interface {
+	RegisterHandler(verb string, path string, h HandlerFunc, a ...RouteOption)
+	readReq(w http.ResponseWriter, r *http.Request, req interface{}) bool
+}
+
+// EndpointFunc is a typed endpoint, called with request decoded into *Req
+type EndpointFunc[Req, Resp any] func(c context.Context, w http.ResponseWriter, req *Req) (Resp, error)
+
+// Handle registers typed endpoint with svc (a service embedding BaseService), eg:
+//
+//	http.Handle(svc, "POST", "/create", func(c context.Context, w http.ResponseWriter, req *createReq) (*order, error) {...})
+//
+// It behaves the same as RegisterEndpoint (request is decoded, bound and validated,
+// returned errors are responded with), while being checked at compile time
+// and calling fn without reflection.
+// Resp of type *Response is responded with as is, other values
+// are responded with as data with 200 status.
+// It panics if Req has invalid validate tags.
+func Handle[Req, Resp any](svc endpointService, verb string, path string, fn EndpointFunc[Req, Resp], a ...RouteOption) {
+	if err := checkValidation(reflect.TypeOf((*Req)(nil)).Elem()); err != nil {
+		panic(err)
+	}
+
+	svc.RegisterHandler(verb, path, func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+		ok := svc.readReq(w, r, req)
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		if !ok {
+			return
+		}
+
+		c = context.WithValue(c, contextReqKey, r)
+
+		resp, err := fn(c, w, req)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		switch v := interface{}(resp).(type) {
+		case nil:
+			respond.WithJSON(w, r, NewResponse(nil, http.StatusOK))
+		case *Response:
+			if v == nil {
+				v = NewResponse(nil, http.StatusOK)
+			}
+			respond.WithJSON(w, r, v)
+		default:
+			respond.WithJSON(w, r, NewResponse(resp, http.StatusOK))
+		}
+	}, a...)
+}
@@ -0,0 +1,173 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type handleReq struct {
+	ID   int64  `path:"id"`
+	Name string `json:"name"`
+}
+
+func (r *handleReq) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+type handleResp struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type handleSvc struct {
+	http.BaseService
+}
+
+func (s *handleSvc) Prefix() string { return "handle" }
+
+func TestHandle(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := handleSvc{}
+	http.Handle(&svc, "POST", "/{id}/data", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*handleResp, error) {
+		if http.ReqFromCtx(c) == nil {
+			return nil, fmt.Errorf("no request in context")
+		}
+		return &handleResp{ID: req.ID, Name: req.Name}, nil
+	})
+	http.Handle(&svc, "POST", "/{id}/response", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*http.Response, error) {
+		return http.NewResponse(req.Name, gohttp.StatusCreated), nil
+	})
+	http.Handle(&svc, "POST", "/{id}/empty", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*http.Response, error) {
+		return nil, nil
+	})
+	http.Handle(&svc, "POST", "/{id}/http_error", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*http.Response, error) {
+		return nil, http.NewError(gohttp.StatusConflict, fmt.Errorf("already exists"))
+	})
+	http.Handle(&svc, "POST", "/{id}/error", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (interface{}, error) {
+		return nil, fmt.Errorf("something failed")
+	})
+	s.MustRegisterService(&svc)
+
+	cases := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "test data",
+			path:     "/handle/7/data",
+			body:     `{"name":"John"}`,
+			wantCode: 200,
+			wantBody: `{"code":200,"data":{"id":7,"name":"John"}}`,
+		},
+		{
+			name:     "test response",
+			path:     "/handle/7/response",
+			body:     `{"name":"John"}`,
+			wantCode: 201,
+			wantBody: `{"code":201,"data":"John"}`,
+		},
+		{
+			name:     "test nil response",
+			path:     "/handle/7/empty",
+			body:     `{"name":"John"}`,
+			wantCode: 200,
+			wantBody: `{"code":200}`,
+		},
+		{
+			name:     "test http error",
+			path:     "/handle/7/http_error",
+			body:     `{"name":"John"}`,
+			wantCode: 409,
+			wantBody: `{"code":409,"errors":["already exists"]}`,
+		},
+		{
+			name:     "test error",
+			path:     "/handle/7/error",
+			body:     `{"name":"John"}`,
+			wantCode: 500,
+			wantBody: `{"code":500,"errors":["something failed"]}`,
+		},
+		{
+			name:     "test validation",
+			path:     "/handle/7/data",
+			body:     `{}`,
+			wantCode: 400,
+			wantBody: `{"code":400,"errors":["could not validate request: name is required"]}`,
+		},
+		{
+			name:     "test decode error",
+			path:     "/handle/7/data",
+			body:     `{`,
+			wantCode: 400,
+		},
+		{
+			name:     "test bind error",
+			path:     "/handle/x/data",
+			body:     `{"name":"John"}`,
+			wantCode: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("POST", c.path, strings.NewReader(c.body)))
+
+			assert.Equal(t, c.wantCode, w.Code)
+			if c.wantBody != "" {
+				assert.JSONEq(t, c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func benchmarkEndpoint(b *testing.B, register func(*handleSvc)) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := handleSvc{}
+	register(&svc)
+	s.MustRegisterService(&svc)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/handle/7/data", strings.NewReader(`{"name":"John"}`)))
+		if w.Code != 200 {
+			b.Fatalf("unexpected status %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkRegisterEndpoint(b *testing.B) {
+	benchmarkEndpoint(b, func(svc *handleSvc) {
+		svc.MustRegisterEndpoint("POST", "/{id}/data", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*http.Response, error) {
+			return http.NewResponse(&handleResp{ID: req.ID, Name: req.Name}, 200), nil
+		})
+	})
+}
+
+func BenchmarkHandle(b *testing.B) {
+	benchmarkEndpoint(b, func(svc *handleSvc) {
+		http.Handle(svc, "POST", "/{id}/data", func(c context.Context, w gohttp.ResponseWriter, req *handleReq) (*handleResp, error) {
+			return &handleResp{ID: req.ID, Name: req.Name}, nil
+		})
+	})
+}
@@ -20,15 +20,16 @@ type Service interface {
 type Endpoint struct {
 	Methods []string
 	Handler HandlerFunc
+	Meta    Meta
 }
 
 // HandlerFunc represents kit http handler func
 type HandlerFunc func(context.Context, http.ResponseWriter, *http.Request)
 
 // ServeHTTP implements http.Handler for HandlerFunc so it can be chained
-// with third party middleware
+// with third party middleware. Handler is called with request context.
 func (hf HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	hf(context.Background(), w, r)
+	hf(r.Context(), w, r)
 }
 
 // Endpoints represents a map of service endpoints
@@ -55,6 +56,15 @@ func (r *Response) Code() int { return r.code }
 // Body returns associated response body
 func (r *Response) Body() interface{} { return r.body }
 
+// RequestIDKey is used to store request id to context
+const RequestIDKey = "tonto_http_request_id_key"
+
+// RequestIDFromCtx returns request id associated with context
+func RequestIDFromCtx(c context.Context) string {
+	id, _ := c.Value(ContextKey(RequestIDKey)).(string)
+	return id
+}
+
 const contextReqKey = "tonto_http_request_key"
 
 // ReqFromCtx returns http request associated with context
@@ -0,0 +1,295 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// StrictJSONKey is used to store strict json decoding flag to context
+const StrictJSONKey = "tonto_http_strict_json_key"
+
+// StrictJSON returns an adapter enabling strict decoding of json requests,
+// which rejects unknown fields, duplicate keys and data following the json value.
+// It can be used per endpoint, per service (BaseService.Adapt) or server wide (WithAdapters).
+func StrictJSON() Adapter {
+	return func(h HandlerFunc) HandlerFunc {
+		return func(c context.Context, w http.ResponseWriter, r *http.Request) {
+			c = context.WithValue(c, ContextKey(StrictJSONKey), true)
+			h(c, w, r.WithContext(c))
+		}
+	}
+}
+
+func strictJSONFromCtx(c context.Context) bool {
+	strict, _ := c.Value(ContextKey(StrictJSONKey)).(bool)
+	return strict
+}
+
+// decodeJSON decodes json request, reporting type mismatches
+// with json path and expected type (eg. items[2].qty: expected integer)
+func decodeJSON(r *http.Request, v interface{}) error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading request: %v", err)
+	}
+
+	if strictJSONFromCtx(r.Context()) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if err := checkJSON(dec, reflect.TypeOf(v), ""); err != nil {
+			return jsonError(data, err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return NewError(http.StatusBadRequest, fmt.Errorf("invalid json: unexpected data after top-level value"))
+		}
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return jsonError(data, err)
+	}
+
+	return nil
+}
+
+func jsonError(data []byte, err error) *Error {
+	switch e := err.(type) {
+	case *Error:
+		return e
+	case *json.UnmarshalTypeError:
+		err = NewFieldError(jsonPathAt(data, e.Offset), "type", "expected "+jsonType(e.Type))
+	case *json.SyntaxError:
+		err = fmt.Errorf("invalid json: %v", e)
+	default:
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("invalid json: unexpected end of JSON input")
+		}
+	}
+	return NewError(http.StatusBadRequest, err)
+}
+
+// jsonType describes json value expected for t
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	}
+	return t.String()
+}
+
+// jsonPathAt returns path (eg. items[2].qty) of the value
+// in data ending at (or opened before) offset
+func jsonPathAt(data []byte, offset int64) string {
+	type frame struct {
+		array bool
+		index int
+		key   string
+		path  string
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	stack := []*frame{}
+	expectKey := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			expectKey = len(stack) > 0 && !stack[len(stack)-1].array
+			continue
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if top != nil && !top.array && expectKey {
+			top.key = tok.(string)
+			expectKey = false
+			continue
+		}
+
+		path := ""
+		if top != nil {
+			if top.array {
+				path = fmt.Sprintf("%s[%d]", top.path, top.index)
+				top.index++
+			} else {
+				path = joinJSONPath(top.path, top.key)
+				expectKey = true
+			}
+		}
+
+		if dec.InputOffset() >= offset {
+			return path
+		}
+
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &frame{array: d == '[', path: path})
+			expectKey = d == '{'
+		}
+	}
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// checkJSON walks json value checking for duplicate keys, and
+// for keys not matching any field of struct type t expects at their path
+func checkJSON(dec *json.Decoder, t reflect.Type, path string) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(jsonUnmarshalerType)) {
+		t = nil
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]bool)
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := kt.(string)
+			kpath := joinJSONPath(path, key)
+
+			// Struct fields are matched case insensitively,
+			// so keys differing in case only set the same field
+			seenKey := key
+			if t != nil && t.Kind() == reflect.Struct {
+				seenKey = strings.ToLower(key)
+			}
+			if seen[seenKey] {
+				return NewError(http.StatusBadRequest, NewFieldError(kpath, "duplicate", "duplicate key"))
+			}
+			seen[seenKey] = true
+
+			var ft reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					var ok bool
+					if ft, ok = jsonFields(t)[strings.ToLower(key)]; !ok {
+						return NewError(http.StatusBadRequest, NewFieldError(kpath, "unknown", "unknown field"))
+					}
+				case reflect.Map:
+					ft = t.Elem()
+				}
+			}
+
+			if err := checkJSON(dec, ft, kpath); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+
+	case json.Delim('['):
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		for i := 0; dec.More(); i++ {
+			if err := checkJSON(dec, et, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+
+	return nil
+}
+
+var jsonFieldsCache sync.Map
+
+// jsonFields returns types of fields of struct type t (including promoted
+// ones of embedded structs) by lower cased json name, matching the
+// case insensitive way encoding/json matches keys to fields
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if fields, ok := jsonFieldsCache.Load(t); ok {
+		return fields.(map[string]reflect.Type)
+	}
+
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for n, f := range jsonFields(ft) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = f
+					}
+				}
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields[strings.ToLower(name)] = sf.Type
+	}
+
+	jsonFieldsCache.Store(t, fields)
+
+	return fields
+}
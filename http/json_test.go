@@ -0,0 +1,221 @@
+package http_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type jsonMeta struct {
+	Source string `json:"source"`
+}
+
+type jsonItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type jsonOrder struct {
+	jsonMeta
+
+	Customer string              `json:"customer"`
+	Items    []jsonItem          `json:"items"`
+	Labels   map[string]jsonItem `json:"labels"`
+	Extra    interface{}         `json:"extra"`
+	Due      *time.Time          `json:"due"`
+	Note     string
+	Internal string `json:"-"`
+}
+
+type jsonSvc struct {
+	http.BaseService
+}
+
+func (s *jsonSvc) Prefix() string { return "orders" }
+
+func TestJSONDecoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "test valid",
+			path:     "/orders/strict",
+			body:     `{"customer":"John","source":"web","note":"x","items":[{"sku":"a","qty":1}],"labels":{"a":{"qty":1}},"extra":{"any":{"thing":1}},"due":"2024-03-01T10:00:00Z"}`,
+			wantCode: 200,
+		},
+		{
+			name:     "test type mismatch path",
+			path:     "/orders/lenient",
+			body:     `{"items":[{"qty":1},{"qty":2},{"qty":"three"}]}`,
+			wantCode: 400,
+			wantErr:  `items[2].qty: expected integer`,
+		},
+		{
+			name:     "test type mismatch object",
+			path:     "/orders/lenient",
+			body:     `{"items":{"qty":1}}`,
+			wantCode: 400,
+			wantErr:  `items: expected array`,
+		},
+		{
+			name:     "test type mismatch map value",
+			path:     "/orders/strict",
+			body:     `{"labels":{"a":{"sku":1}}}`,
+			wantCode: 400,
+			wantErr:  `labels.a.sku: expected string`,
+		},
+		{
+			name:     "test type mismatch root",
+			path:     "/orders/lenient",
+			body:     `[1]`,
+			wantCode: 400,
+			wantErr:  `expected object`,
+		},
+		{
+			name:     "test syntax error",
+			path:     "/orders/lenient",
+			body:     `{"customer":}`,
+			wantCode: 400,
+			wantErr:  `invalid json: invalid character '}' looking for beginning of value`,
+		},
+		{
+			name:     "test unexpected end",
+			path:     "/orders/strict",
+			body:     `{"customer":"John"`,
+			wantCode: 400,
+			wantErr:  `invalid json: unexpected end of JSON input`,
+		},
+		{
+			name:     "test unknown field allowed",
+			path:     "/orders/lenient",
+			body:     `{"custmer":"John","internal":"x"} trailing`,
+			wantCode: 200,
+		},
+		{
+			name:     "test unknown field",
+			path:     "/orders/strict",
+			body:     `{"items":[{"sku":"a"},{"skus":"b"}]}`,
+			wantCode: 400,
+			wantErr:  `items[1].skus: unknown field`,
+		},
+		{
+			name:     "test ignored field",
+			path:     "/orders/strict",
+			body:     `{"internal":"x"}`,
+			wantCode: 400,
+			wantErr:  `internal: unknown field`,
+		},
+		{
+			name:     "test duplicate key",
+			path:     "/orders/strict",
+			body:     `{"customer":"John","customer":"Jane"}`,
+			wantCode: 400,
+			wantErr:  `customer: duplicate key`,
+		},
+		{
+			name:     "test duplicate key differing in case",
+			path:     "/orders/strict",
+			body:     `{"items":[{"qty":1,"QTY":2}]}`,
+			wantCode: 400,
+			wantErr:  `items[0].QTY: duplicate key`,
+		},
+		{
+			name:     "test map keys differing in case",
+			path:     "/orders/strict",
+			body:     `{"labels":{"a":{},"A":{}}}`,
+			wantCode: 200,
+		},
+		{
+			name:     "test trailing data",
+			path:     "/orders/strict",
+			body:     `{"customer":"John"} {}`,
+			wantCode: 400,
+			wantErr:  `invalid json: unexpected data after top-level value`,
+		},
+		{
+			name:     "test strict service",
+			path:     "/customers/service",
+			body:     `{"custmer":"John"}`,
+			wantCode: 400,
+			wantErr:  `custmer: unknown field`,
+		},
+	}
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	ep := func(c context.Context, w gohttp.ResponseWriter, req *jsonOrder) error { return nil }
+
+	svc := jsonSvc{}
+	svc.MustRegisterEndpoint("POST", "/lenient", ep)
+	svc.MustRegisterEndpoint("POST", "/strict", ep, http.StrictJSON())
+	s.MustRegisterService(&svc)
+
+	strictSvc := bindSvc{}
+	strictSvc.MustRegisterEndpoint("POST", "/service", ep)
+	strictSvc.Adapt(http.StrictJSON())
+	s.MustRegisterService(&strictSvc)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("POST", c.path, strings.NewReader(c.body)))
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), c.wantErr)
+			assert.NotContains(t, w.Body.String(), "internal error")
+		})
+	}
+}
+
+func TestRegisterEndpoint_JSONErrors(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	ep := func(c context.Context, w gohttp.ResponseWriter, req *jsonOrder) error { return nil }
+
+	svc := jsonSvc{}
+	svc.MustRegisterEndpoint("POST", "/lenient", ep)
+	svc.MustRegisterEndpoint("POST", "/strict", ep, http.StrictJSON())
+	s.MustRegisterService(&svc)
+
+	cases := []struct {
+		name     string
+		path     string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "test type mismatch",
+			path:     "/orders/lenient",
+			body:     `{"items":[{"qty":"one"}]}`,
+			wantBody: `{"code":400,"errors":["items[0].qty: expected integer"]}`,
+		},
+		{
+			name:     "test strict unknown field",
+			path:     "/orders/strict",
+			body:     `{"custmer":"John"}`,
+			wantBody: `{"code":400,"errors":["custmer: unknown field"]}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("POST", c.path, strings.NewReader(c.body)))
+
+			assert.Equal(t, 400, w.Code)
+			assert.JSONEq(t, c.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// Middleware represents standard net/http middleware
+type Middleware func(http.Handler) http.Handler
+
+// FromMiddleware converts standard net/http middleware into Adapter,
+// so that third party middleware can be used with kit servers and services.
+// Middleware gets the request carrying adapter context, and context
+// of the request it passes on becomes handler context, eg:
+//
+//	svc.Adapt(http.FromMiddleware(gziphandler.GzipHandler))
+func FromMiddleware(mw Middleware) Adapter {
+	return func(h HandlerFunc) HandlerFunc {
+		next := mw(h)
+		return func(c context.Context, w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(c))
+		}
+	}
+}
+
+// ToMiddleware converts Adapter into standard net/http middleware, so that
+// kit adapters can be used with any net/http router. Adapter gets request
+// context, and context it passes on is set on the request next handler gets.
+func ToMiddleware(a Adapter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return a(func(c context.Context, w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(c))
+		})
+	}
+}
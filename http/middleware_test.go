@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type ctxKey string
+
+func stdMiddleware(key, value string) http.Middleware {
+	return func(next gohttp.Handler) gohttp.Handler {
+		return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey(key), value)))
+		})
+	}
+}
+
+func kitAdapter(key, value string) http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			// request is deliberately passed on as is
+			h(context.WithValue(c, ctxKey(key), value), w, r)
+		}
+	}
+}
+
+func TestHandlerFunc_ServeHTTP(t *testing.T) {
+	c, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey("std"), "value"), time.Minute)
+	defer cancel()
+
+	var got context.Context
+	http.HandlerFunc(func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		got = c
+	}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(c))
+
+	_, ok := got.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, "value", got.Value(ctxKey("std")))
+}
+
+func TestFromMiddleware(t *testing.T) {
+	s := http.NewServer(
+		http.WithLogger(log.New(ioutil.Discard, "", 0)),
+		http.WithAdapters(
+			http.FromMiddleware(stdMiddleware("server_std", "1")),
+			kitAdapter("server_kit", "2"),
+		),
+	)
+
+	var c, rc context.Context
+
+	svc := hsvc{}
+	svc.RegisterHandler("GET", "/values", func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		c, rc = ctx, r.Context()
+	}, http.FromMiddleware(stdMiddleware("std", "3")), kitAdapter("kit", "4"))
+	s.MustRegisterService(&svc)
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/svc/values", nil))
+
+	for _, ctx := range []context.Context{c, rc} {
+		assert.Equal(t, "1", ctx.Value(ctxKey("server_std")))
+		assert.Equal(t, "2", ctx.Value(ctxKey("server_kit")))
+		assert.Equal(t, "3", ctx.Value(ctxKey("std")))
+		assert.Equal(t, "4", ctx.Value(ctxKey("kit")))
+		assert.NotNil(t, http.RouteFromCtx(ctx))
+	}
+}
+
+func TestToMiddleware(t *testing.T) {
+	var c context.Context
+
+	var h gohttp.Handler = gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		c = r.Context()
+	})
+	h = http.ToMiddleware(kitAdapter("kit", "1"))(h)
+	h = stdMiddleware("std", "2")(h)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "1", c.Value(ctxKey("kit")))
+	assert.Equal(t, "2", c.Value(ctxKey("std")))
+}
@@ -11,7 +11,8 @@ import (
 type ServerOption func(*Server)
 
 // WithAdapters represents server option for setting up
-// server-wide request adapters
+// server-wide request adapters (the last one is outermost,
+// use Chain for the reverse order)
 func WithAdapters(a ...Adapter) ServerOption {
 	return func(s *Server) {
 		s.adapters = a
@@ -2,6 +2,7 @@
 package respond
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	gohttp "net/http"
@@ -10,9 +11,40 @@ import (
 var marshalError = `{"code":500,"errors":["request was successful but we were unable to encode the response."]}`
 
 type response struct {
-	Code   int         `json:"code"`
-	Data   interface{} `json:"data,omitempty"`
-	Errors []string    `json:"errors,omitempty"`
+	Code    int         `json:"code"`
+	Data    interface{} `json:"data,omitempty"`
+	Errors  []string    `json:"errors,omitempty"`
+	Fields  []field     `json:"fields,omitempty"`
+	TraceID string      `json:"trace_id,omitempty"`
+}
+
+type field struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ctxKey string
+
+const traceIDKey ctxKey = "tonto_http_trace_id_key"
+
+// WithTraceID returns a copy of ctx carrying trace id, which is
+// included in error responses written for requests with such context
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey, id)
+}
+
+// TraceIDFromCtx returns trace id associated with context
+func TraceIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey).(string)
+	return id
+}
+
+func traceID(r *gohttp.Request) string {
+	if r == nil {
+		return ""
+	}
+	return TraceIDFromCtx(r.Context())
 }
 
 type httpResponse interface {
@@ -25,9 +57,34 @@ type httpError interface {
 	Errs() []error
 }
 
+type fieldError interface {
+	Field() string
+	Rule() string
+	Message() string
+}
+
+// fields collects field errors err is or wraps
+func fields(err error) []field {
+	switch e := err.(type) {
+	case fieldError:
+		return []field{{Field: e.Field(), Code: e.Rule(), Message: e.Message()}}
+	case interface{ Unwrap() []error }:
+		var fs []field
+		for _, err := range e.Unwrap() {
+			fs = append(fs, fields(err)...)
+		}
+		return fs
+	case interface{ Unwrap() error }:
+		return fields(e.Unwrap())
+	}
+	return nil
+}
+
 // WithJSON makes a new json response based on a given response interface
 // If provided resp is of type errors.Error error response will be made,
-// otherwise provider resp will be json encoded and written to w
+// otherwise provider resp will be json encoded and written to w.
+// Field errors (eg. http.FieldError) of 422 responses are additionally listed
+// in fields section, which is the status plain errors consisting of them get.
 func WithJSON(w gohttp.ResponseWriter, r *gohttp.Request, resp interface{}) {
 	w.Header().Add("Content-Type", "application/json")
 
@@ -39,13 +96,13 @@ func WithJSON(w gohttp.ResponseWriter, r *gohttp.Request, resp interface{}) {
 
 	herr, ok := resp.(httpError)
 	if ok {
-		writeError(w, herr)
+		writeError(w, herr, traceID(r))
 		return
 	}
 
 	err, ok := resp.(error)
 	if ok {
-		writeSimpleError(w, err)
+		writeSimpleError(w, err, traceID(r))
 		return
 	}
 
@@ -70,28 +127,45 @@ func writeResponse(w gohttp.ResponseWriter, resp httpResponse) {
 	)
 }
 
-func writeError(w gohttp.ResponseWriter, e httpError) {
+func writeError(w gohttp.ResponseWriter, e httpError, traceID string) {
 	w.WriteHeader(e.Code())
-	var errs []string
-	for _, e := range e.Errs() {
-		errs = append(errs, e.Error())
+	var (
+		errs []string
+		fs   []field
+	)
+	for _, err := range e.Errs() {
+		errs = append(errs, err.Error())
+		// Fields section goes with 422 responses only,
+		// others (eg. 400 decoding errors) just list errors
+		if e.Code() == gohttp.StatusUnprocessableEntity {
+			fs = append(fs, fields(err)...)
+		}
 	}
 	writeJSON(
 		w,
 		response{
-			Code:   e.Code(),
-			Errors: errs,
+			Code:    e.Code(),
+			Errors:  errs,
+			Fields:  fs,
+			TraceID: traceID,
 		},
 	)
 }
 
-func writeSimpleError(w gohttp.ResponseWriter, err error) {
-	w.WriteHeader(gohttp.StatusInternalServerError)
+func writeSimpleError(w gohttp.ResponseWriter, err error, traceID string) {
+	code := gohttp.StatusInternalServerError
+	fs := fields(err)
+	if fs != nil {
+		code = gohttp.StatusUnprocessableEntity
+	}
+	w.WriteHeader(code)
 	writeJSON(
 		w,
 		response{
-			Code:   gohttp.StatusInternalServerError,
-			Errors: []string{err.Error()},
+			Code:    code,
+			Errors:  []string{err.Error()},
+			Fields:  fs,
+			TraceID: traceID,
 		},
 	)
 }
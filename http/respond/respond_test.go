@@ -60,6 +60,40 @@ func TestWithJSON(t *testing.T) {
 			want:     `{"code":400,"errors":["error 1","error 2"]}`,
 			wantCode: gohttp.StatusBadRequest,
 		},
+		{
+			name: "test field errs",
+			resp: http.NewError(
+				gohttp.StatusUnprocessableEntity,
+				http.NewFieldError("items[2].qty", "min", "must be at least 1"),
+				fmt.Errorf("error 2"),
+			),
+			want:     `{"code":422,"errors":["items[2].qty: must be at least 1","error 2"],"fields":[{"field":"items[2].qty","code":"min","message":"must be at least 1"}]}`,
+			wantCode: gohttp.StatusUnprocessableEntity,
+		},
+		{
+			name: "test field errs without 422",
+			resp: http.NewError(
+				gohttp.StatusBadRequest,
+				http.NewFieldError("items[2].qty", "type", "expected integer"),
+			),
+			want:     `{"code":400,"errors":["items[2].qty: expected integer"]}`,
+			wantCode: gohttp.StatusBadRequest,
+		},
+		{
+			name: "test simple field errs",
+			simpleErr: http.FieldErrors{
+				http.NewFieldError("email", "email", "must be a valid email address"),
+				http.NewFieldError("name", "required", "is required"),
+			},
+			want:     `{"code":422,"errors":["email: must be a valid email address; name: is required"],"fields":[{"field":"email","code":"email","message":"must be a valid email address"},{"field":"name","code":"required","message":"is required"}]}`,
+			wantCode: gohttp.StatusUnprocessableEntity,
+		},
+		{
+			name:      "test wrapped field err",
+			simpleErr: fmt.Errorf("invalid request: %w", http.NewFieldError("name", "required", "is required")),
+			want:      `{"code":422,"errors":["invalid request: name: is required"],"fields":[{"field":"name","code":"required","message":"is required"}]}`,
+			wantCode:  gohttp.StatusUnprocessableEntity,
+		},
 		{
 			name: "test marshal err",
 			resp: http.NewResponse(
@@ -106,3 +140,16 @@ func (s str) MarshalJSON() ([]byte, error) {
 	}
 	return []byte(fmt.Sprintf("\"%s\"", s)), nil
 }
+
+func TestWithJSON_TraceID(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(respond.WithTraceID(r.Context(), "4bf92f3577b34da6a3ce929d0e0e4736"))
+
+	w := httptest.NewRecorder()
+	respond.WithJSON(w, r, http.NewError(gohttp.StatusBadRequest, fmt.Errorf("an error")))
+	assert.Equal(t, `{"code":400,"errors":["an error"],"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	respond.WithJSON(w, r, "ok")
+	assert.Equal(t, `{"code":200,"data":"ok"}`+"\n", w.Body.String())
+}
@@ -0,0 +1,82 @@
+package http
+
+import "context"
+
+// Meta represents endpoint metadata such as authorization requirements.
+// It is listed with registered routes and can be used by adapters
+// to select endpoints they apply to.
+type Meta map[string][]string
+
+// Route represents registered server route
+type Route struct {
+	// Service is the name of the service type route belongs to
+	Service string
+
+	// Path is the route path template (eg. /customer/details/{id})
+	Path string
+
+	Methods []string
+	Meta    Meta
+}
+
+// RouteKey is used to store matched route to context
+const RouteKey = "tonto_http_route_key"
+
+// RouteFromCtx returns the route matched for the request associated with context
+func RouteFromCtx(c context.Context) *Route {
+	if r, ok := c.Value(ContextKey(RouteKey)).(*Route); ok {
+		return r
+	}
+	return nil
+}
+
+// RouteOption is accepted by endpoint registration (eg. BaseService.RegisterEndpoint).
+// It is either an Adapter, or a MetaAdapter declaring endpoint metadata as well.
+type RouteOption interface {
+	applyRoute(e *Endpoint)
+}
+
+func (a Adapter) applyRoute(e *Endpoint) { e.Handler = a(e.Handler) }
+
+// MetaAdapter represents an adapter declaring metadata of endpoints
+// it is registered with, which is listed in the route table
+// (see Server.Routes) and can be matched by adapters
+type MetaAdapter struct {
+	Adapter Adapter
+	Meta    Meta
+}
+
+// WithMeta creates MetaAdapter declaring meta along with adapter a, eg:
+//
+//	func RequireFoo() http.MetaAdapter {
+//		return http.WithMeta(http.Meta{"foo": {"required"}}, func(h http.HandlerFunc) http.HandlerFunc {
+//			return func(c context.Context, w http.ResponseWriter, r *http.Request) {...}
+//		})
+//	}
+func WithMeta(meta Meta, a Adapter) MetaAdapter {
+	return MetaAdapter{Adapter: a, Meta: meta}
+}
+
+func (m MetaAdapter) applyRoute(e *Endpoint) {
+	if len(m.Meta) > 0 && e.Meta == nil {
+		e.Meta = Meta{}
+	}
+	for k, v := range m.Meta {
+		e.Meta[k] = append(e.Meta[k], v...)
+	}
+	if m.Adapter != nil {
+		e.Handler = m.Adapter(e.Handler)
+	}
+}
+
+// newEndpoint creates endpoint serving hf decorated with route options
+func newEndpoint(verb string, hf HandlerFunc, opts ...RouteOption) *Endpoint {
+	e := &Endpoint{
+		Methods: []string{verb},
+		Handler: hf,
+	}
+	for _, o := range opts {
+		o.applyRoute(e)
+	}
+	return e
+}
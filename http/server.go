@@ -9,7 +9,9 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -31,7 +33,9 @@ func NewServer(opts ...ServerOption) *Server {
 			IdleTimeout: 120 * time.Second,
 		},
 		stop:         make(chan os.Signal, 1),
+		closing:      make(chan struct{}),
 		mux:          mux.NewRouter().StrictSlash(true),
+		muxRoutes:    make(map[*mux.Route]*Route),
 		readTimeout:  5 * time.Second,
 		writeTimeout: 10 * time.Second,
 	}
@@ -49,6 +53,7 @@ func NewServer(opts ...ServerOption) *Server {
 
 	srv.httpServer.WriteTimeout = srv.writeTimeout
 	srv.httpServer.ReadTimeout = srv.readTimeout
+	srv.httpServer.RegisterOnShutdown(srv.close)
 
 	var hf HandlerFunc
 	h := srv.httpServer.Handler
@@ -61,7 +66,13 @@ func NewServer(opts ...ServerOption) *Server {
 		hf = apt(hf)
 	}
 
-	srv.httpServer.Handler = hf
+	// Route is matched up front so that server-wide
+	// adapters can find it in the context as well
+	srv.httpServer.Handler = HandlerFunc(func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		c = context.WithValue(c, ContextKey(serverKey), &srv)
+		c = srv.routeCtx(c, r)
+		hf(c, w, r.WithContext(c))
+	})
 
 	if srv.logger == nil {
 		srv.logger = log.New(os.Stdout, "kit/http => ", log.Ldate|log.Ltime|log.Llongfile)
@@ -88,8 +99,13 @@ type Server struct {
 	certFile        string
 	keyFile         string
 	mux             *mux.Router
+	routes          []Route
+	muxRoutes       map[*mux.Route]*Route
 	notFoundHandler http.Handler
 	stop            chan os.Signal
+	closing         chan struct{}
+	closeOnce       sync.Once
+	conns           connTracker
 	writeTimeout    time.Duration
 	readTimeout     time.Duration
 }
@@ -130,7 +146,7 @@ func (s *Server) Run(port int) error {
 
 	s.logger.Printf("%sServer shutting down...%s", rColor, nColor)
 
-	e := s.httpServer.Shutdown(context.Background())
+	e := s.Shutdown(context.Background())
 	if e != nil {
 		return e
 	}
@@ -159,11 +175,43 @@ func (s *Server) runTLS() error {
 	return s.httpServer.ListenAndServeTLS(s.certFile, s.keyFile)
 }
 
+// ServeHTTP implements http.Handler so the server can be
+// mounted onto another server or used with httptest
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.httpServer.Handler.ServeHTTP(w, r)
+}
+
 // Stop attempts to gracefully shutdown the server
 func (s *Server) Stop() {
 	s.stop <- os.Interrupt
 }
 
+// Shutdown gracefully shuts down the server, waiting for active requests to
+// complete until ctx is done. Long lived connections (event streams and
+// websockets) are closed right away, websocket ones with going away status.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.close()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.conns.wait(ctx)
+}
+
+func (s *Server) close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+}
+
+// WebSocketConns returns the number of open websocket connections
+func (s *Server) WebSocketConns() int { return s.conns.count() }
+
+const serverKey = "tonto_http_server_key"
+
+// serverFromCtx returns server serving request associated with context
+func serverFromCtx(c context.Context) *Server {
+	srv, _ := c.Value(ContextKey(serverKey)).(*Server)
+	return srv
+}
+
 // MustRegisterServices panic version of RegisterServices
 func (s *Server) MustRegisterServices(svcs ...Service) {
 	if err := s.RegisterServices(svcs...); err != nil {
@@ -218,16 +266,27 @@ func (s *Server) RegisterService(svc Service) error {
 		s.printRouteInfo(svc, path, endpoint)
 		hfunc := endpoint.Handler
 
+		rt := &Route{
+			Service: st.Name(),
+			Path:    s.getPath(path, svc.Prefix()),
+			Methods: endpoint.Methods,
+			Meta:    endpoint.Meta,
+		}
+		s.routes = append(s.routes, *rt)
+
 		route := s.mux.HandleFunc(
-			s.getPath(path, svc.Prefix()),
+			rt.Path,
 			func(w http.ResponseWriter, r *http.Request) {
-				hfunc(r.Context(), w, r)
+				c := context.WithValue(r.Context(), ContextKey(RouteKey), rt)
+				hfunc(c, w, r.WithContext(c))
 			},
 		)
 
 		if endpoint.Methods != nil {
-			route.Methods(endpoint.Methods...)
+			route.Methods(withHead(endpoint.Methods)...)
 		}
+
+		s.muxRoutes[route] = rt
 	}
 
 	s.logger.Println("")
@@ -235,11 +294,63 @@ func (s *Server) RegisterService(svc Service) error {
 	return nil
 }
 
+// withHead adds HEAD to methods of GET endpoints, so that
+// they are answered automatically (net/http omits the body)
+func withHead(methods []string) []string {
+	get := false
+	for _, m := range methods {
+		switch strings.ToUpper(m) {
+		case http.MethodHead:
+			return methods
+		case http.MethodGet:
+			get = true
+		}
+	}
+	if !get {
+		return methods
+	}
+	return append(methods[:len(methods):len(methods)], http.MethodHead)
+}
+
+func (s *Server) routeCtx(c context.Context, r *http.Request) context.Context {
+	var m mux.RouteMatch
+	if !s.mux.Match(r, &m) || m.Route == nil {
+		return c
+	}
+	if rt, ok := s.muxRoutes[m.Route]; ok {
+		return context.WithValue(c, ContextKey(RouteKey), rt)
+	}
+	return c
+}
+
+// Routes returns the table of registered routes sorted by path,
+// along with the meta (eg. authorization requirements) of each
+func (s *Server) Routes() []Route {
+	routes := make([]Route, len(s.routes))
+	copy(routes, s.routes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
 func (s *Server) printRouteInfo(svc Service, path string, ep *Endpoint) {
+	keys := make([]string, 0, len(ep.Meta))
+	for k := range ep.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	meta := ""
+	for _, k := range keys {
+		meta += fmt.Sprintf(" %s=%s", k, strings.Join(ep.Meta[k], ","))
+	}
+
 	s.logger.Printf(
-		"%s%s %s/%s %s",
+		"%s%s %s/%s%s%s %s",
 		yColor, strings.Join(ep.Methods, ","),
 		gColor, svc.Prefix()+path,
+		wColor, meta,
 		nColor,
 	)
 }
@@ -6,8 +6,10 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	gohttp "net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -264,3 +266,29 @@ func (s *hsvc) postEndpointGErr(c context.Context, w gohttp.ResponseWriter, rq *
 func (s *hsvc) postEndpointHErr(c context.Context, w gohttp.ResponseWriter, rq *req) (*http.Response, error) {
 	return nil, http.NewError(gohttp.StatusBadRequest, fmt.Errorf("endpoint error"))
 }
+
+func TestServer_Routes(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	var route *http.Route
+
+	svc := hsvc{}
+	svc.RegisterHandler("GET", "/details/{id}", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		route = http.RouteFromCtx(c)
+	}, http.WithMeta(http.Meta{"authz": {"roles(admin)"}}, func(h http.HandlerFunc) http.HandlerFunc {
+		return h
+	}))
+	svc.RegisterHandler("POST", "/create", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {})
+
+	s.MustRegisterService(&svc)
+
+	want := []http.Route{
+		{Service: "hsvc", Path: "/svc/create", Methods: []string{"POST"}},
+		{Service: "hsvc", Path: "/svc/details/{id}", Methods: []string{"GET"}, Meta: http.Meta{"authz": {"roles(admin)"}}},
+	}
+	assert.Equal(t, want, s.Routes())
+
+	req := httptest.NewRequest("GET", "/svc/details/1", nil)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, &want[1], route)
+}
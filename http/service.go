@@ -1,9 +1,10 @@
 package http
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
 	"sync"
@@ -12,7 +13,8 @@ import (
 )
 
 // Validator interface can be implemented by endpoint request
-// types and it will be automatically called by service upon decoding
+// types and it will be automatically called by service upon decoding.
+// Returned *FieldError or FieldErrors are responded with 422 status.
 type Validator interface {
 	Validate() error
 }
@@ -21,7 +23,8 @@ type Validator interface {
 type BaseService struct {
 	m         sync.Mutex
 	endpoints Endpoints
-	mw        []Adapter
+	mw        []RouteOption
+	decoders  map[string]Decoder
 }
 
 // Prefix returns service routing prefix
@@ -30,18 +33,15 @@ func (b *BaseService) Prefix() string { return "/" }
 // RegisterHandler is a helper method that registers service HandlerFunc
 // Service HandlerFunc is an extension of http.HandlerFunc which only adds context.Context
 // as first parameter, the rest stays the same
-func (b *BaseService) RegisterHandler(verb string, path string, h HandlerFunc, a ...Adapter) {
+func (b *BaseService) RegisterHandler(verb string, path string, h HandlerFunc, a ...RouteOption) {
 	if b.endpoints == nil {
 		b.endpoints = make(map[string]*Endpoint)
 	}
-	b.endpoints[path] = &Endpoint{
-		Methods: []string{verb},
-		Handler: AdaptHandlerFunc(h, a...),
-	}
+	b.endpoints[path] = newEndpoint(verb, h, a...)
 }
 
 // MustRegisterEndpoint panic version of RegisterEndpoint
-func (b *BaseService) MustRegisterEndpoint(verb string, path string, method interface{}, a ...Adapter) {
+func (b *BaseService) MustRegisterEndpoint(verb string, path string, method interface{}, a ...RouteOption) {
 	if err := b.RegisterEndpoint(verb, path, method, a...); err != nil {
 		panic(err)
 	}
@@ -51,8 +51,9 @@ func (b *BaseService) MustRegisterEndpoint(verb string, path string, method inte
 // JSON endpoint method should have the following signature:
 // func(c context.Context, w http.ResponseWriter, req *CustomType) (*http.Response, error)
 // where *CustomType is your custom request type to which r.Body will be json unmarshalled automatically
+// (requests without body, eg. GET, get zero valued *CustomType)
 // *http.Response can be omitted if endpoint has no reasonable response, error is always required however
-func (b *BaseService) RegisterEndpoint(verb string, path string, method interface{}, a ...Adapter) error {
+func (b *BaseService) RegisterEndpoint(verb string, path string, method interface{}, a ...RouteOption) error {
 	h, err := b.handlerFromMethod(method)
 	if err != nil {
 		return err
@@ -62,10 +63,7 @@ func (b *BaseService) RegisterEndpoint(verb string, path string, method interfac
 		b.endpoints = make(map[string]*Endpoint)
 	}
 
-	b.endpoints[path] = &Endpoint{
-		Methods: []string{verb},
-		Handler: AdaptHandlerFunc(h, a...),
-	}
+	b.endpoints[path] = newEndpoint(verb, h, a...)
 
 	return nil
 }
@@ -76,25 +74,16 @@ func (b *BaseService) handlerFromMethod(m interface{}) (HandlerFunc, error) {
 		return nil, err
 	}
 
+	reqType := reflect.ValueOf(m).Type().In(2).Elem()
+
 	return func(c context.Context, w http.ResponseWriter, r *http.Request) {
-		req, err := b.decodeReq(r, m)
-		if err != nil {
-			respond.WithJSON(
-				w, r,
-				NewError(http.StatusBadRequest, fmt.Errorf("internal error: could not decode request: %v", err)),
-			)
-			return
+		req := reflect.New(reqType).Interface()
+		ok := b.readReq(w, r, req)
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
 		}
-
-		if validator, ok := interface{}(req).(Validator); ok {
-			err = validator.Validate()
-			if err != nil {
-				respond.WithJSON(
-					w, r,
-					NewError(http.StatusBadRequest, fmt.Errorf("could not validate request: %v", err)),
-				)
-				return
-			}
+		if !ok {
+			return
 		}
 
 		c = context.WithValue(c, contextReqKey, r)
@@ -146,28 +135,104 @@ func (b *BaseService) validateSignature(m interface{}) error {
 		return fmt.Errorf("param two must implement http.ResponseWriter")
 	}
 
+	if t.In(2).Kind() != reflect.Ptr {
+		return fmt.Errorf("param three must be a pointer to request type")
+	}
+
+	if err := checkValidation(t.In(2).Elem()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (b *BaseService) decodeReq(r *http.Request, m interface{}) (interface{}, error) {
+// readReq decodes, binds and validates request into req,
+// responding with error and returning false if any of it fails
+func (b *BaseService) readReq(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	err := b.decodeReq(r, req)
+	if e, ok := err.(*Error); ok {
+		respond.WithJSON(w, r, e)
+		return false
+	}
+	if err != nil {
+		respond.WithJSON(
+			w, r,
+			NewError(http.StatusBadRequest, fmt.Errorf("could not decode request: %v", err)),
+		)
+		return false
+	}
+
+	if err := Bind(r, req); err != nil {
+		respond.WithJSON(w, r, err)
+		return false
+	}
+
+	if err := Validate(req); err != nil {
+		respond.WithJSON(w, r, err)
+		return false
+	}
+
+	if validator, ok := req.(Validator); ok {
+		err = validator.Validate()
+		if fe := fieldErrors(err); fe != nil {
+			respond.WithJSON(w, r, NewError(http.StatusUnprocessableEntity, fe...))
+			return false
+		}
+		if err != nil {
+			respond.WithJSON(
+				w, r,
+				NewError(http.StatusBadRequest, fmt.Errorf("could not validate request: %v", err)),
+			)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *BaseService) decodeReq(r *http.Request, req interface{}) error {
 	defer r.Body.Close()
 
-	v := reflect.ValueOf(m)
-	reqParamType := v.Type().In(2).Elem()
-	req := reflect.New(reqParamType).Interface()
+	// Body-less requests (eg. GET, DELETE) get zero valued request,
+	// leaving it to be bound from path, query etc.
+	if emptyBody(r) {
+		return nil
+	}
 
-	err := json.NewDecoder(r.Body).Decode(req)
+	dec, err := b.decoder(r)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding json: %v", err)
+		return err
+	}
+
+	return dec.Decode(r, req)
+}
+
+// emptyBody reports whether request has no body, peeking into
+// bodies of unknown length (eg. chunked)
+func emptyBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return true
+	}
+	if r.ContentLength > 0 {
+		return false
 	}
 
-	return req, nil
+	br := bufio.NewReader(r.Body)
+	if _, err := br.Peek(1); err == io.EOF {
+		return true
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+
+	return false
 }
 
 func (b *BaseService) writeResponse(w http.ResponseWriter, r *http.Request, ret []reflect.Value) {
 	if len(ret) == 1 {
 		if !ret[0].IsNil() {
-			b.writeError(w, r, ret[0].Interface())
+			writeError(w, r, ret[0].Interface().(error))
 			return
 		}
 		respond.WithJSON(w, r, NewResponse(nil, http.StatusOK))
@@ -175,7 +240,7 @@ func (b *BaseService) writeResponse(w http.ResponseWriter, r *http.Request, ret
 	}
 
 	if !ret[1].IsNil() {
-		b.writeError(w, r, ret[1].Interface())
+		writeError(w, r, ret[1].Interface().(error))
 		return
 	}
 
@@ -188,23 +253,30 @@ func (b *BaseService) writeResponse(w http.ResponseWriter, r *http.Request, ret
 	respond.WithJSON(w, r, resp)
 }
 
-func (b *BaseService) writeError(w http.ResponseWriter, r *http.Request, e interface{}) {
-	if _, ok := e.(*Error); ok {
-		respond.WithJSON(w, r, e)
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if _, ok := err.(*Error); ok {
+		respond.WithJSON(w, r, err)
 		return
 	}
-	respond.WithJSON(w, r, NewError(http.StatusInternalServerError, e.(error)))
+	if fe := fieldErrors(err); fe != nil {
+		respond.WithJSON(w, r, NewError(http.StatusUnprocessableEntity, fe...))
+		return
+	}
+	respond.WithJSON(w, r, NewError(http.StatusInternalServerError, err))
 }
 
 // Endpoints returns all registered endpoints
 func (b *BaseService) Endpoints() Endpoints {
 	for _, e := range b.endpoints {
 		if b.mw != nil {
-			e.Handler = AdaptHandlerFunc(e.Handler, b.mw...)
+			for _, o := range b.mw {
+				o.applyRoute(e)
+			}
 		}
 	}
 	return b.endpoints
 }
 
-// Adapt is used to adapt the service with provided adapters
-func (b *BaseService) Adapt(mw ...Adapter) { b.mw = mw }
+// Adapt is used to adapt the service with provided adapters,
+// meta declared by them (see MetaAdapter) is added to all endpoints
+func (b *BaseService) Adapt(mw ...RouteOption) { b.mw = mw }
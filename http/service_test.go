@@ -5,8 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	gohttp "net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -124,10 +128,10 @@ func TestRegisterHandler_WithEndpointsAndMW(t *testing.T) {
 			s := svc{}
 			s.RegisterHandler(c.verb, c.path, c.h)
 			if c.adapter != nil {
-				apts := []http.Adapter{}
-				apts = append(apts, c.adapter)
+				apts := []http.RouteOption{}
+				apts = append(apts, http.Adapter(c.adapter))
 				if c.aprime != nil {
-					apts = append(apts, c.aprime)
+					apts = append(apts, http.Adapter(c.aprime))
 				}
 				s.Adapt(apts...)
 			}
@@ -347,7 +351,7 @@ func TestRegisterEndpoint_WithEndpointsAndMW(t *testing.T) {
 			assert.Nil(t, err)
 
 			if c.adapter != nil {
-				s.Adapt(c.adapter)
+				s.Adapt(http.Adapter(c.adapter))
 			}
 
 			endpoints := s.Endpoints()
@@ -365,3 +369,112 @@ func TestRegisterEndpoint_WithEndpointsAndMW(t *testing.T) {
 		})
 	}
 }
+
+type readSvc struct {
+	http.BaseService
+}
+
+func (s *readSvc) Prefix() string { return "items" }
+
+func TestRegisterEndpoint_EmptyBody(t *testing.T) {
+	type getReq struct {
+		ID    int64  `path:"id"`
+		Field string `query:"field"`
+	}
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := readSvc{}
+	svc.MustRegisterEndpoint("GET", "/{id}", func(c context.Context, w gohttp.ResponseWriter, req *getReq) (*http.Response, error) {
+		return http.NewResponse(req, 200), nil
+	})
+	svc.MustRegisterEndpoint("DELETE", "/{id}/delete", func(c context.Context, w gohttp.ResponseWriter, req *getReq) error {
+		if req.ID != 7 {
+			return http.NewError(404, fmt.Errorf("not found"))
+		}
+		return nil
+	})
+	svc.MustRegisterEndpoint("POST", "/create", func(c context.Context, w gohttp.ResponseWriter, req *getReq) error {
+		return nil
+	})
+	s.MustRegisterService(&svc)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		body     io.Reader
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "test get",
+			method:   "GET",
+			path:     "/items/7?field=name",
+			wantCode: 200,
+			wantBody: `{"code":200,"data":{"ID":7,"Field":"name"}}`,
+		},
+		{
+			name:     "test head",
+			method:   "HEAD",
+			path:     "/items/7",
+			wantCode: 200,
+		},
+		{
+			name:     "test delete",
+			method:   "DELETE",
+			path:     "/items/7/delete",
+			wantCode: 200,
+		},
+		{
+			name:     "test delete chunked empty body",
+			method:   "DELETE",
+			path:     "/items/8/delete",
+			body:     struct{ io.Reader }{strings.NewReader("")},
+			wantCode: 404,
+		},
+		{
+			name:     "test post empty body",
+			method:   "POST",
+			path:     "/items/create",
+			wantCode: 200,
+		},
+		{
+			name:     "test post invalid body",
+			method:   "POST",
+			path:     "/items/create",
+			body:     strings.NewReader("{"),
+			wantCode: 400,
+		},
+		{
+			name:     "test head not allowed for delete",
+			method:   "HEAD",
+			path:     "/items/7/delete",
+			wantCode: 405,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, _ := gohttp.NewRequest(c.method, ts.URL+c.path, c.body)
+			resp, err := gohttp.DefaultClient.Do(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+
+			b, _ := ioutil.ReadAll(resp.Body)
+
+			assert.Equal(t, c.wantCode, resp.StatusCode)
+			if c.wantBody != "" {
+				assert.JSONEq(t, c.wantBody, string(b))
+			}
+			if c.method == "HEAD" {
+				assert.Empty(t, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamClosed is returned when sending to event stream whose handler has returned
+var ErrStreamClosed = errors.New("http: event stream closed")
+
+// DefaultHeartbeat is the interval at which comments are sent to event
+// streams, keeping idle connections (and proxies in between) alive
+const DefaultHeartbeat = 15 * time.Second
+
+// Event represents server-sent event
+type Event struct {
+	// ID is remembered by the client and sent back
+	// as Last-Event-ID header once it reconnects
+	ID string
+
+	// Type is event type (message if empty)
+	Type string
+
+	// Retry tells client how long to wait before reconnecting
+	Retry time.Duration
+
+	// Data is event payload. Strings and byte slices
+	// are sent as they are, other values json encoded
+	Data interface{}
+}
+
+// StreamFunc handles server-sent events stream. Context is done once client
+// disconnects or server shuts down, after which StreamFunc should return.
+// Request can be obtained from context with ReqFromCtx (eg. to Bind it).
+// Errors returned before anything is sent are responded with the
+// same as endpoint errors, otherwise the stream is just closed.
+type StreamFunc func(c context.Context, s *EventStream) error
+
+// RegisterStream registers GET endpoint streaming server-sent events (text/event-stream)
+func (b *BaseService) RegisterStream(path string, f StreamFunc, a ...RouteOption) {
+	b.RegisterHandler(http.MethodGet, path, streamHandler(f), a...)
+}
+
+func streamHandler(f StreamFunc) HandlerFunc {
+	return func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		c, cancel := context.WithCancel(context.WithValue(c, contextReqKey, r))
+		defer cancel()
+
+		s := &EventStream{
+			ctx:         c,
+			w:           w,
+			rc:          http.NewResponseController(w),
+			lastEventID: r.Header.Get("Last-Event-ID"),
+		}
+
+		// Streams outlive server write timeout
+		s.rc.SetWriteDeadline(time.Time{})
+
+		if r.Method == http.MethodHead {
+			s.start()
+			return
+		}
+
+		if srv := serverFromCtx(c); srv != nil {
+			go func() {
+				select {
+				case <-srv.closing:
+					cancel()
+				case <-c.Done():
+				}
+			}()
+		}
+
+		stop := s.keepAlive(c, DefaultHeartbeat)
+		err := f(c, s)
+		stop()
+
+		if started := s.close(); err != nil && !started {
+			writeError(w, r, err)
+		}
+	}
+}
+
+// EventStream represents server-sent events stream. It is safe for concurrent use.
+type EventStream struct {
+	mu          sync.Mutex
+	ctx         context.Context
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	lastEventID string
+	started     bool
+	closed      bool
+	err         error
+	heartbeat   *time.Ticker
+}
+
+// LastEventID returns id of the last event client received
+// before reconnecting (empty for new clients)
+func (s *EventStream) LastEventID() string { return s.lastEventID }
+
+// Send writes event to the stream and flushes it to the client.
+// It fails once the stream context is done.
+func (s *EventStream) Send(e Event) error {
+	if strings.ContainsAny(e.ID+e.Type, "\r\n") {
+		return fmt.Errorf("http: event id and type can not contain newlines")
+	}
+
+	var buf bytes.Buffer
+
+	if e.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", e.ID)
+	}
+	if e.Type != "" {
+		fmt.Fprintf(&buf, "event: %s\n", e.Type)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry.Milliseconds())
+	}
+
+	if e.Data != nil {
+		data, err := eventData(e.Data)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(data, "\n") {
+			fmt.Fprintf(&buf, "data: %s\n", line)
+		}
+	}
+
+	buf.WriteString("\n")
+
+	return s.write(buf.Bytes())
+}
+
+// SetHeartbeat changes the interval of heartbeat comments (DefaultHeartbeat),
+// disabling them if d is 0
+func (s *EventStream) SetHeartbeat(d time.Duration) {
+	if d <= 0 {
+		s.heartbeat.Stop()
+		return
+	}
+	s.heartbeat.Reset(d)
+}
+
+func eventData(v interface{}) (string, error) {
+	var data string
+
+	switch d := v.(type) {
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("http: could not encode event data: %v", err)
+		}
+		data = string(b)
+	}
+
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data), nil
+}
+
+// start writes stream headers, it is called with mu held
+func (s *EventStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	h := s.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *EventStream) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.start()
+
+	if _, err := s.w.Write(b); err != nil {
+		s.err = err
+		return err
+	}
+	if err := s.rc.Flush(); err != nil {
+		s.err = fmt.Errorf("http: could not flush event stream: %v", err)
+		return s.err
+	}
+
+	return nil
+}
+
+// keepAlive sends heartbeat comments until c is done or returned func is called
+func (s *EventStream) keepAlive(c context.Context, d time.Duration) func() {
+	s.heartbeat = time.NewTicker(d)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-s.heartbeat.C:
+				s.write([]byte(":\n\n"))
+			case <-c.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		s.heartbeat.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
+// close prevents further writes, reporting whether the stream has been started
+func (s *EventStream) close() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.started
+}
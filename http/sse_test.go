@@ -0,0 +1,188 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type streamSvc struct {
+	http.BaseService
+}
+
+func (s *streamSvc) Prefix() string { return "orders" }
+
+func TestRegisterStream(t *testing.T) {
+	var wrapped http.Adapter = func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			h(c, http.WrapResponseWriter(w), r)
+		}
+	}
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := streamSvc{}
+	svc.RegisterStream("/{id}/events", func(c context.Context, s *http.EventStream) error {
+		var req struct {
+			ID int `path:"id"`
+		}
+		if err := http.Bind(http.ReqFromCtx(c), &req); err != nil || req.ID != 1 {
+			return http.NewError(gohttp.StatusBadRequest, fmt.Errorf("invalid order id"))
+		}
+		if s.LastEventID() == "2" {
+			return s.Send(http.Event{ID: "3", Data: "resumed"})
+		}
+		events := []http.Event{
+			{ID: "1", Type: "status", Retry: 3 * time.Second, Data: map[string]string{"status": "paid"}},
+			{ID: "2", Data: "line 1\nline 2"},
+			{Type: "done"},
+		}
+		for _, e := range events {
+			if err := s.Send(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, wrapped)
+	svc.RegisterStream("/{id}/history", func(c context.Context, s *http.EventStream) error {
+		return http.NewError(gohttp.StatusNotFound, fmt.Errorf("order not found"))
+	})
+	s.MustRegisterService(&svc)
+
+	cases := []struct {
+		name        string
+		path        string
+		lastEventID string
+		wantCode    int
+		wantType    string
+		wantBody    string
+	}{
+		{
+			name:     "test events",
+			path:     "/orders/1/events",
+			wantCode: 200,
+			wantType: "text/event-stream",
+			wantBody: "id: 1\nevent: status\nretry: 3000\ndata: {\"status\":\"paid\"}\n\n" +
+				"id: 2\ndata: line 1\ndata: line 2\n\n" +
+				"event: done\n\n",
+		},
+		{
+			name:        "test resume",
+			path:        "/orders/1/events",
+			lastEventID: "2",
+			wantCode:    200,
+			wantType:    "text/event-stream",
+			wantBody:    "id: 3\ndata: resumed\n\n",
+		},
+		{
+			name:     "test error",
+			path:     "/orders/1/history",
+			wantCode: 404,
+			wantType: "application/json",
+			wantBody: `{"code":404,"errors":["order not found"]}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", c.path, nil)
+			if c.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", c.lastEventID)
+			}
+			w := httptest.NewRecorder()
+
+			s.ServeHTTP(w, req)
+
+			assert.Equal(t, c.wantCode, w.Code)
+			assert.Equal(t, c.wantType, w.Header().Get("Content-Type"))
+			assert.Equal(t, c.wantBody, w.Body.String())
+			if c.wantCode == 200 {
+				assert.True(t, w.Flushed)
+			}
+		})
+	}
+}
+
+func TestRegisterStream_Lifecycle(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	done := make(chan error, 1)
+
+	svc := streamSvc{}
+	svc.RegisterStream("/events", func(c context.Context, s *http.EventStream) error {
+		s.SetHeartbeat(10 * time.Millisecond)
+		if err := s.Send(http.Event{Data: "hello"}); err != nil {
+			return err
+		}
+		<-c.Done()
+		done <- s.Send(http.Event{Data: "bye"})
+		return nil
+	})
+	s.MustRegisterService(&svc)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	read := func(t *testing.T) (*bufio.Reader, func()) {
+		resp, err := gohttp.Get(ts.URL + "/orders/events")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+
+	t.Run("test heartbeat and disconnect", func(t *testing.T) {
+		rdr, close := read(t)
+
+		var lines []string
+		for len(lines) < 4 {
+			l, err := rdr.ReadString('\n')
+			if !assert.NoError(t, err) {
+				return
+			}
+			lines = append(lines, l)
+		}
+		assert.Equal(t, []string{"data: hello\n", "\n", ":\n", "\n"}, lines)
+
+		close()
+
+		select {
+		case err := <-done:
+			assert.Equal(t, context.Canceled, err)
+		case <-time.After(time.Second):
+			t.Fatal("stream not closed on client disconnect")
+		}
+	})
+
+	t.Run("test shutdown", func(t *testing.T) {
+		rdr, close := read(t)
+		defer close()
+
+		l, _ := rdr.ReadString('\n')
+		assert.Equal(t, "data: hello\n", l)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, s.Shutdown(ctx))
+
+		select {
+		case err := <-done:
+			assert.Equal(t, context.Canceled, err)
+		case <-time.After(time.Second):
+			t.Fatal("stream not closed on shutdown")
+		}
+
+		rest, err := ioutil.ReadAll(rdr)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(rest), "bye")
+	})
+}
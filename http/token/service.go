@@ -0,0 +1,331 @@
+// Package token provides a kit http service issuing jwt access tokens
+// and rotating refresh tokens
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	gohttp "net/http"
+	"time"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+	jwt "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// Identity represents authenticated subject tokens are issued for
+type Identity struct {
+	// Subject is stored as access token sub claim
+	Subject string
+
+	// Claims are added to access token claims
+	Claims map[string]interface{}
+}
+
+// CredentialChecker checks login credentials. Implementors should
+// return nil Identity if credentials are not valid.
+type CredentialChecker interface {
+	CheckCredentials(ctx context.Context, username, password string) (*Identity, error)
+}
+
+// CredentialCheckerFunc is a func implementation of CredentialChecker
+type CredentialCheckerFunc func(ctx context.Context, username, password string) (*Identity, error)
+
+// CheckCredentials calls f(ctx, username, password)
+func (f CredentialCheckerFunc) CheckCredentials(ctx context.Context, username, password string) (*Identity, error) {
+	return f(ctx, username, password)
+}
+
+// StoreChecker creates CredentialChecker checking credentials against
+// adapter.CredentialStore (the same one used with adapter.WithBasicAuth)
+// with adapter.VerifyCredential
+func StoreChecker(store adapter.CredentialStore, hash adapter.SecretHash) CredentialChecker {
+	return CredentialCheckerFunc(func(ctx context.Context, username, password string) (*Identity, error) {
+		cred, err := adapter.VerifyCredential(ctx, store, hash, username, []byte(password))
+		if err != nil || cred == nil {
+			return nil, err
+		}
+
+		sub := cred.PrincipalID
+		if sub == "" {
+			sub = username
+		}
+
+		return &Identity{Subject: sub, Claims: cred.Claims}, nil
+	})
+}
+
+// Option represents token service option
+type Option func(*Service)
+
+// NewService creates new token service signing access tokens with provided alg
+// and key (secret for HMAC algs or a private key for asymmetric ones), and storing
+// refresh tokens to provided store.
+//
+// Service exposes the following endpoints (under auth prefix by default):
+//
+// POST /login   {"username": "", "password": ""}
+// POST /refresh {"refresh_token": ""}
+// POST /revoke  {"refresh_token": ""}
+//
+// Refresh tokens are rotated upon each refresh, and presenting an already
+// rotated refresh token revokes the whole token family, as it
+// indicates that the token has been stolen.
+func NewService(alg adapter.JWTAlg, key interface{}, checker CredentialChecker, store RefreshStore, opts ...Option) *Service {
+	svc := Service{
+		alg:        alg,
+		key:        key,
+		checker:    checker,
+		store:      store,
+		prefix:     "auth",
+		accessTTL:  15 * time.Minute,
+		refreshTTL: 30 * 24 * time.Hour,
+	}
+
+	for _, o := range opts {
+		o(&svc)
+	}
+
+	svc.MustRegisterEndpoint("POST", "/login", svc.login)
+	svc.MustRegisterEndpoint("POST", "/refresh", svc.refresh)
+	svc.MustRegisterEndpoint("POST", "/revoke", svc.revoke)
+
+	return &svc
+}
+
+// Service represents token issuing http service
+type Service struct {
+	http.BaseService
+
+	alg        adapter.JWTAlg
+	key        interface{}
+	kid        string
+	checker    CredentialChecker
+	store      RefreshStore
+	prefix     string
+	issuer     string
+	audience   []string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// Prefix returns service routing prefix
+func (s *Service) Prefix() string { return s.prefix }
+
+type loginReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Validate validates login request
+func (r *loginReq) Validate() error {
+	if r.Username == "" || r.Password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+	return nil
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Validate validates refresh request
+func (r *refreshReq) Validate() error {
+	if r.RefreshToken == "" {
+		return fmt.Errorf("refresh_token is required")
+	}
+	return nil
+}
+
+// Tokens represents issued token pair
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *Service) login(c context.Context, w gohttp.ResponseWriter, req *loginReq) (*http.Response, error) {
+	id, err := s.checker.CheckCredentials(c, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("invalid credentials"))
+	}
+
+	family, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(c, id, family)
+}
+
+func (s *Service) refresh(c context.Context, w gohttp.ResponseWriter, req *refreshReq) (*http.Response, error) {
+	id := hashToken(req.RefreshToken)
+
+	rt, err := s.store.Get(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if rt == nil || rt.Revoked || time.Now().After(rt.ExpiresAt) {
+		return nil, http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
+	}
+
+	ok, err := s.store.MarkUsed(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.store.RevokeFamily(c, rt.Family); err != nil {
+			return nil, err
+		}
+		return nil, http.NewError(gohttp.StatusUnauthorized, fmt.Errorf("refresh token reuse detected"))
+	}
+
+	return s.issue(c, &Identity{Subject: rt.Subject, Claims: rt.Claims}, rt.Family)
+}
+
+func (s *Service) revoke(c context.Context, w gohttp.ResponseWriter, req *refreshReq) error {
+	rt, err := s.store.Get(c, hashToken(req.RefreshToken))
+	if err != nil {
+		return err
+	}
+	// Unknown tokens are not reported (RFC 7009)
+	if rt == nil {
+		return nil
+	}
+	return s.store.RevokeFamily(c, rt.Family)
+}
+
+func (s *Service) issue(c context.Context, id *Identity, family string) (*http.Response, error) {
+	now := time.Now()
+
+	access, err := s.accessToken(id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.Save(c, &RefreshToken{
+		ID:        hashToken(refresh),
+		Family:    family,
+		Subject:   id.Subject,
+		Claims:    id.Claims,
+		ExpiresAt: now.Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewResponse(
+		Tokens{
+			AccessToken:  access,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(s.accessTTL / time.Second),
+			RefreshToken: refresh,
+		},
+		gohttp.StatusOK,
+	), nil
+}
+
+func (s *Service) accessToken(id *Identity, now time.Time) (string, error) {
+	claims := jwt.MapClaims{}
+	for k, v := range id.Claims {
+		claims[k] = v
+	}
+
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	claims["sub"] = id.Subject
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.accessTTL).Unix()
+	claims["jti"] = jti
+
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+
+	switch len(s.audience) {
+	case 0:
+	case 1:
+		claims["aud"] = s.audience[0]
+	default:
+		claims["aud"] = s.audience
+	}
+
+	t := jwt.NewWithClaims(s.alg, claims)
+	if s.kid != "" {
+		t.Header["kid"] = s.kid
+	}
+
+	return t.SignedString(s.key)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Refresh tokens are stored by their hash only
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithPrefix sets service routing prefix ("auth" by default)
+func WithPrefix(prefix string) Option {
+	return func(s *Service) {
+		s.prefix = prefix
+	}
+}
+
+// WithKeyID sets kid header of issued access tokens
+func WithKeyID(kid string) Option {
+	return func(s *Service) {
+		s.kid = kid
+	}
+}
+
+// WithIssuer sets iss claim of issued access tokens
+func WithIssuer(iss string) Option {
+	return func(s *Service) {
+		s.issuer = iss
+	}
+}
+
+// WithAudience sets aud claim of issued access tokens
+func WithAudience(aud ...string) Option {
+	return func(s *Service) {
+		s.audience = aud
+	}
+}
+
+// WithAccessTTL sets access token lifetime (15m by default)
+func WithAccessTTL(d time.Duration) Option {
+	return func(s *Service) {
+		s.accessTTL = d
+	}
+}
+
+// WithRefreshTTL sets refresh token lifetime (30 days by default)
+func WithRefreshTTL(d time.Duration) Option {
+	return func(s *Service) {
+		s.refreshTTL = d
+	}
+}
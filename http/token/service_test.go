@@ -0,0 +1,138 @@
+package token_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http/adapter"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/http/token"
+)
+
+func TestService(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	svc := token.NewService(
+		adapter.JWTAlgES256,
+		key,
+		checker,
+		token.NewMemoryStore(),
+		token.WithIssuer("kit"),
+	)
+
+	assert.Equal(t, "auth", svc.Prefix())
+
+	code, resp := call(svc, "/login", map[string]string{"username": "john", "password": "wrong"})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, []string{"invalid credentials"}, resp.Errors)
+
+	code, resp = call(svc, "/login", map[string]string{"username": "john"})
+	assert.Equal(t, 400, code)
+
+	code, resp = call(svc, "/login", map[string]string{"username": "john", "password": "secret"})
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "Bearer", resp.Data.TokenType)
+	assert.Equal(t, int64(900), resp.Data.ExpiresIn)
+
+	// Access token is accepted by the jwt auth adapter
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	auth := adapter.WithJWTAuth(
+		adapter.JWTAlgES256,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		func(ctx context.Context, token string, claims map[string]interface{}) error { return nil },
+		adapter.WithJWTIssuer("kit"),
+	)
+	hdlr := auth(func(ctx context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		claims := adapter.JWTClaimsFromCtx(ctx)
+		respond.WithJSON(w, r, claims["sub"].(string)+":"+claims["role"].(string))
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Data.AccessToken)
+	w := httptest.NewRecorder()
+	hdlr(context.Background(), w, req)
+	assert.Equal(t, `{"code":200,"data":"john:admin"}`+"\n", w.Body.String())
+
+	first := resp.Data.RefreshToken
+
+	// Refresh rotates the refresh token
+	code, resp = call(svc, "/refresh", map[string]string{"refresh_token": first})
+	assert.Equal(t, 200, code)
+	assert.NotEmpty(t, resp.Data.AccessToken)
+	assert.NotEqual(t, first, resp.Data.RefreshToken)
+
+	second := resp.Data.RefreshToken
+
+	// Reusing rotated token revokes the whole family
+	code, resp = call(svc, "/refresh", map[string]string{"refresh_token": first})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, []string{"refresh token reuse detected"}, resp.Errors)
+
+	code, resp = call(svc, "/refresh", map[string]string{"refresh_token": second})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, []string{"invalid refresh token"}, resp.Errors)
+
+	code, resp = call(svc, "/refresh", map[string]string{"refresh_token": "unknown"})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, []string{"invalid refresh token"}, resp.Errors)
+}
+
+func TestService_Revoke(t *testing.T) {
+	svc := token.NewService(
+		adapter.JWTAlgHS256,
+		[]byte("secret"),
+		checker,
+		token.NewMemoryStore(),
+	)
+
+	_, resp := call(svc, "/login", map[string]string{"username": "john", "password": "secret"})
+	refresh := resp.Data.RefreshToken
+
+	code, _ := call(svc, "/revoke", map[string]string{"refresh_token": refresh})
+	assert.Equal(t, 200, code)
+
+	code, resp = call(svc, "/refresh", map[string]string{"refresh_token": refresh})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, []string{"invalid refresh token"}, resp.Errors)
+
+	code, _ = call(svc, "/revoke", map[string]string{"refresh_token": "unknown"})
+	assert.Equal(t, 200, code)
+}
+
+var checker = token.StoreChecker(
+	adapter.CredentialStoreFunc(func(ctx context.Context, id string) (*adapter.Credential, error) {
+		if id != "john" {
+			return nil, nil
+		}
+		return &adapter.Credential{Secret: []byte("secret"), Claims: map[string]interface{}{"role": "admin"}}, nil
+	}),
+	adapter.PlainSecret,
+)
+
+type response struct {
+	Code   int          `json:"code"`
+	Data   token.Tokens `json:"data"`
+	Errors []string     `json:"errors"`
+}
+
+func call(svc *token.Service, path string, body interface{}) (int, response) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/auth"+path, bytes.NewReader(data))
+	w := httptest.NewRecorder()
+
+	svc.Endpoints()[path].Handler(context.Background(), w, req)
+
+	resp := response{}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	return w.Code, resp
+}
@@ -0,0 +1,115 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RefreshToken represents stored refresh token
+type RefreshToken struct {
+	// ID is the hash of the token, tokens themselves are never stored
+	ID string
+
+	// Family is shared by all the tokens rotated from the same login
+	Family string
+
+	Subject   string
+	Claims    map[string]interface{}
+	ExpiresAt time.Time
+
+	// Used is set once the token has been rotated
+	Used bool
+
+	Revoked bool
+}
+
+// RefreshStore represents refresh token storage
+type RefreshStore interface {
+	// Save stores new refresh token
+	Save(context.Context, *RefreshToken) error
+
+	// Get returns refresh token by id or nil if there is none
+	Get(ctx context.Context, id string) (*RefreshToken, error)
+
+	// MarkUsed atomically marks the token as used, returning
+	// false if it had already been used before
+	MarkUsed(ctx context.Context, id string) (bool, error)
+
+	// RevokeFamily revokes all tokens of a family
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+// NewMemoryStore creates new in-memory RefreshStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: make(map[string]*RefreshToken),
+	}
+}
+
+// MemoryStore represents in-memory RefreshStore
+// Expired tokens are pruned upon saving new ones.
+type MemoryStore struct {
+	m      sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+// Save stores new refresh token
+func (s *MemoryStore) Save(ctx context.Context, rt *RefreshToken) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	now := time.Now()
+	for id, t := range s.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+
+	t := *rt
+	s.tokens[rt.ID] = &t
+
+	return nil
+}
+
+// Get returns refresh token by id or nil if there is none
+func (s *MemoryStore) Get(ctx context.Context, id string) (*RefreshToken, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, nil
+	}
+
+	rt := *t
+	return &rt, nil
+}
+
+// MarkUsed atomically marks the token as used
+func (s *MemoryStore) MarkUsed(ctx context.Context, id string) (bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || t.Used {
+		return false, nil
+	}
+
+	t.Used = true
+	return true, nil
+}
+
+// RevokeFamily revokes all tokens of a family
+func (s *MemoryStore) RevokeFamily(ctx context.Context, family string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Family == family {
+			t.Revoked = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validate checks fields of the struct v points to against rules
+// declared with validate tag, eg:
+//
+//	type createReq struct {
+//		Email string  `json:"email" validate:"required,email"`
+//		Name  string  `json:"name" validate:"required,max=64"`
+//		Role  string  `json:"role" validate:"omitempty,oneof=admin user"`
+//		Items []item  `json:"items" validate:"min=1"`
+//	}
+//
+//	type item struct {
+//		Qty int `json:"qty" validate:"min=1,max=100"`
+//	}
+//
+// Supported rules are required, omitempty (skips other rules for zero values),
+// min, max and len (length of strings, slices and maps, or value of numbers),
+// oneof (space separated values), email and url.
+// Nested structs, and structs within slices and maps are validated as well.
+// All violations are returned as *Error with 422 status, consisting of
+// FieldError per violation, naming the offending field by its json path
+// (eg. items[2].qty: must be at least 1) and the violated rule.
+//
+// Endpoints registered with RegisterEndpoint have their requests
+// validated after decoding, before Validator is called.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []error
+	validateStruct(rv.Elem(), "", &errs)
+
+	if len(errs) > 0 {
+		return NewError(http.StatusUnprocessableEntity, errs...)
+	}
+
+	return nil
+}
+
+type validationRule struct {
+	code  string
+	n     float64
+	oneOf []string
+}
+
+type validatedField struct {
+	index     []int
+	name      string
+	omitempty bool
+	rules     []validationRule
+	nested    bool
+}
+
+var validationCache sync.Map
+
+// checkValidation reports invalid validate tags of struct type t and types nested within it
+func checkValidation(t reflect.Type) error {
+	return checkValidationTypes(t, make(map[reflect.Type]bool))
+}
+
+func checkValidationTypes(t reflect.Type, seen map[reflect.Type]bool) error {
+	t = nestedStruct(t)
+	if t == nil || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	fields, err := validationFields(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := checkValidationTypes(t.FieldByIndex(f.index).Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedStruct returns struct type validated within values of type t
+// (t itself, or element type of pointers, slices, arrays and maps)
+func nestedStruct(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			return t
+		default:
+			return nil
+		}
+	}
+}
+
+// validationFields returns fields of struct type t (including ones of
+// embedded structs) having validation rules or nested structs, caching them per type
+func validationFields(t reflect.Type) ([]validatedField, error) {
+	if fields, ok := validationCache.Load(t); ok {
+		return fields.([]validatedField), nil
+	}
+
+	var fields []validatedField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+			embedded, err := validationFields(sf.Type)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range embedded {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		f := validatedField{
+			index:  []int{i},
+			name:   fieldName(sf),
+			nested: nestedStruct(sf.Type) != nil,
+		}
+
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, r := range strings.Split(tag, ",") {
+				if r == "omitempty" {
+					f.omitempty = true
+					continue
+				}
+				rule, err := parseRule(r)
+				if err != nil {
+					return nil, fmt.Errorf("invalid validate tag of %s.%s: %v", t, sf.Name, err)
+				}
+				f.rules = append(f.rules, rule)
+			}
+		}
+
+		if f.nested || len(f.rules) > 0 {
+			fields = append(fields, f)
+		}
+	}
+
+	validationCache.Store(t, fields)
+
+	return fields, nil
+}
+
+// fieldName returns name field is known by to clients (json or bind tag name)
+func fieldName(sf reflect.StructField) string {
+	for _, src := range append([]string{"json", "form"}, bindSources...) {
+		if name := strings.Split(sf.Tag.Get(src), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func parseRule(s string) (validationRule, error) {
+	code, param := s, ""
+	if i := strings.Index(s, "="); i != -1 {
+		code, param = s[:i], s[i+1:]
+	}
+
+	rule := validationRule{code: code}
+
+	switch code {
+	case "required", "email", "url":
+		if param != "" {
+			return rule, fmt.Errorf("rule %s takes no param", code)
+		}
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return rule, fmt.Errorf("rule %s requires numeric param", code)
+		}
+		rule.n = n
+	case "oneof":
+		rule.oneOf = strings.Fields(param)
+		if len(rule.oneOf) == 0 {
+			return rule, fmt.Errorf("rule oneof requires values")
+		}
+	default:
+		return rule, fmt.Errorf("unknown rule %q", code)
+	}
+
+	return rule, nil
+}
+
+func validateStruct(v reflect.Value, path string, errs *[]error) {
+	fields, err := validationFields(v.Type())
+	if err != nil {
+		*errs = append(*errs, err)
+		return
+	}
+
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		fpath := joinJSONPath(path, f.name)
+
+		if isEmpty(fv) {
+			if f.omitempty {
+				continue
+			}
+			if hasRule(f.rules, "required") {
+				*errs = append(*errs, NewFieldError(fpath, "required", "is required"))
+				continue
+			}
+		}
+
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr {
+			continue
+		}
+
+		for _, r := range f.rules {
+			if msg := r.check(fv); msg != "" {
+				*errs = append(*errs, NewFieldError(fpath, r.code, msg))
+			}
+		}
+
+		if f.nested {
+			validateNested(fv, fpath, errs)
+		}
+	}
+}
+
+func validateNested(v reflect.Value, path string, errs *[]error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			validateNested(v.Elem(), path, errs)
+		}
+	case reflect.Struct:
+		validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			validateNested(v.MapIndex(k), joinJSONPath(path, fmt.Sprint(k)), errs)
+		}
+	}
+}
+
+func hasRule(rules []validationRule, code string) bool {
+	for _, r := range rules {
+		if r.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// check returns violation message if v does not satisfy the rule
+func (r validationRule) check(v reflect.Value) string {
+	switch r.code {
+	case "min", "max", "len":
+		n, unit, ok := measure(v)
+		if !ok {
+			return ""
+		}
+
+		var bound string
+		switch {
+		case r.code == "min" && n < r.n:
+			bound = "at least"
+		case r.code == "max" && n > r.n:
+			bound = "at most"
+		case r.code == "len" && n != r.n:
+			bound = "exactly"
+		default:
+			return ""
+		}
+
+		num := strconv.FormatFloat(r.n, 'f', -1, 64)
+		switch unit {
+		case "characters":
+			return fmt.Sprintf("must be %s %s characters long", bound, num)
+		case "items":
+			return fmt.Sprintf("must contain %s %s items", bound, num)
+		}
+		return fmt.Sprintf("must be %s %s", bound, num)
+
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, o := range r.oneOf {
+			if s == o {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of: %s", strings.Join(r.oneOf, ", "))
+
+	case "email":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		if a, err := mail.ParseAddress(v.String()); err != nil || a.Address != v.String() {
+			return "must be a valid email address"
+		}
+
+	case "url":
+		if v.Kind() != reflect.String {
+			return ""
+		}
+		if u, err := url.ParseRequestURI(v.String()); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid url"
+		}
+	}
+
+	return ""
+}
+
+// measure returns length of strings (in characters), slices and maps (in items),
+// or value of numbers, along with unit of the measure
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
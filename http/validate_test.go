@@ -0,0 +1,208 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+)
+
+type validateAudit struct {
+	CreatedBy string `json:"created_by" validate:"required"`
+}
+
+type validateLine struct {
+	SKU string `json:"sku" validate:"required,len=8"`
+	Qty int    `json:"qty" validate:"min=1,max=100"`
+}
+
+type validateReq struct {
+	validateAudit
+
+	Email    string                  `json:"email" validate:"required,email"`
+	Name     string                  `json:"name" validate:"required,max=5"`
+	Role     string                  `json:"role" validate:"omitempty,oneof=admin user"`
+	Website  *string                 `json:"website" validate:"omitempty,url"`
+	Discount float64                 `json:"discount" validate:"max=0.5"`
+	Tenant   string                  `header:"X-Tenant" validate:"required"`
+	Lines    []validateLine          `json:"lines" validate:"min=1"`
+	Gifts    map[string]validateLine `json:"gifts"`
+	Shipping *validateLine           `json:"shipping"`
+}
+
+func TestValidate(t *testing.T) {
+	site := "example.com"
+
+	valid := func() validateReq {
+		return validateReq{
+			validateAudit: validateAudit{CreatedBy: "john"},
+			Email:         "john@example.com",
+			Name:          "John",
+			Tenant:        "acme",
+			Lines:         []validateLine{{SKU: "ABCD1234", Qty: 1}},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		req      func() validateReq
+		wantErrs []string
+	}{
+		{
+			name: "test valid",
+			req:  valid,
+		},
+		{
+			name: "test required",
+			req: func() validateReq {
+				return validateReq{}
+			},
+			wantErrs: []string{
+				"created_by: is required",
+				"email: is required",
+				"name: is required",
+				"X-Tenant: is required",
+				"lines: must contain at least 1 items",
+			},
+		},
+		{
+			name: "test rules",
+			req: func() validateReq {
+				r := valid()
+				r.Email = "John <john@example.com>"
+				r.Name = "Johnny"
+				r.Role = "root"
+				r.Website = &site
+				r.Discount = 0.75
+				return r
+			},
+			wantErrs: []string{
+				"email: must be a valid email address",
+				"name: must be at most 5 characters long",
+				"role: must be one of: admin, user",
+				"website: must be a valid url",
+				"discount: must be at most 0.5",
+			},
+		},
+		{
+			name: "test nested",
+			req: func() validateReq {
+				r := valid()
+				r.Lines = append(r.Lines, validateLine{SKU: "ABC", Qty: 0}, validateLine{SKU: "ABCD1234", Qty: 101})
+				r.Gifts = map[string]validateLine{"b": {SKU: "ABCD1234"}, "a": {Qty: 1}}
+				r.Shipping = &validateLine{SKU: "ABCD1234"}
+				return r
+			},
+			wantErrs: []string{
+				"lines[1].sku: must be exactly 8 characters long",
+				"lines[1].qty: must be at least 1",
+				"lines[2].qty: must be at most 100",
+				"gifts.a.sku: is required",
+				"gifts.b.qty: must be at least 1",
+				"shipping.qty: must be at least 1",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := c.req()
+			err := http.Validate(&req)
+
+			if c.wantErrs == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			if assert.Error(t, err) {
+				var got []string
+				for _, e := range err.(*http.Error).Errs() {
+					got = append(got, e.Error())
+				}
+				assert.Equal(t, c.wantErrs, got)
+				assert.Equal(t, 422, err.(*http.Error).Code())
+			}
+		})
+	}
+}
+
+type validatorReq struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func (r *validatorReq) Validate() error {
+	switch r.Name {
+	case "admin":
+		return fmt.Errorf("name is reserved")
+	case "guest":
+		return fmt.Errorf("could not check name: %w", http.NewFieldError("name", "reserved", "is reserved"))
+	case "root":
+		return http.FieldErrors{
+			http.NewFieldError("name", "reserved", "is reserved"),
+			http.NewFieldError("role", "required", "is required for root"),
+		}
+	}
+	return nil
+}
+
+func TestRegisterEndpoint_Validate(t *testing.T) {
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := bindSvc{}
+	svc.MustRegisterEndpoint("POST", "/validate", func(c context.Context, w gohttp.ResponseWriter, req *validatorReq) error {
+		return nil
+	})
+	s.MustRegisterService(&svc)
+
+	cases := []struct {
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{body: `{"name":"John"}`, wantCode: 200, wantBody: `{"code":200}`},
+		{
+			body:     `{}`,
+			wantCode: 422,
+			wantBody: `{"code":422,"errors":["name: is required"],"fields":[{"field":"name","code":"required","message":"is required"}]}`,
+		},
+		{
+			body:     `{"name":"admin"}`,
+			wantCode: 400,
+			wantBody: `{"code":400,"errors":["could not validate request: name is reserved"]}`,
+		},
+		{
+			body:     `{"name":"guest"}`,
+			wantCode: 422,
+			wantBody: `{"code":422,"errors":["could not check name: name: is reserved"],"fields":[{"field":"name","code":"reserved","message":"is reserved"}]}`,
+		},
+		{
+			body:     `{"name":"root"}`,
+			wantCode: 422,
+			wantBody: `{"code":422,"errors":["name: is reserved","role: is required for root"],"fields":[{"field":"name","code":"reserved","message":"is reserved"},{"field":"role","code":"required","message":"is required for root"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/customers/validate", strings.NewReader(c.body)))
+
+		assert.Equal(t, c.wantCode, w.Code)
+		assert.JSONEq(t, c.wantBody, w.Body.String())
+	}
+
+	type invalidReq struct {
+		Name string `validate:"required,max=ten"`
+	}
+
+	err := svc.RegisterEndpoint("POST", "/invalid", func(c context.Context, w gohttp.ResponseWriter, req *invalidReq) error {
+		return nil
+	})
+	assert.EqualError(t, err, "invalid validate tag of http_test.invalidReq.Name: rule max requires numeric param")
+}
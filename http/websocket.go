@@ -0,0 +1,415 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tonto/kit/http/respond"
+)
+
+var (
+	// ErrConnClosed is returned by Conn once the connection is closed,
+	// either by the client, the handler or server shutdown
+	ErrConnClosed = errors.New("http: websocket connection closed")
+
+	// ErrSlowConsumer is returned by Conn.WriteJSON if the client does not keep up
+	// with messages written to it, in which case the connection is closed
+	ErrSlowConsumer = errors.New("http: websocket client too slow")
+)
+
+// ConnFunc handles websocket connection. Connection is closed once it returns,
+// with normal closure status, or internal error status if it returns an error.
+// Context is done once the connection is closed by the client or server shuts down.
+// Upgrade request can be obtained from context with ReqFromCtx.
+type ConnFunc func(c context.Context, conn *Conn) error
+
+// WebSocket represents websocket endpoint
+type WebSocket struct {
+	// Handler handles accepted connections
+	Handler ConnFunc
+
+	// ReadLimit is the max size of messages read from the client (1MB by default)
+	ReadLimit int64
+
+	// SendQueue is the number of messages buffered for the client (16 by default).
+	// Once it is full, writes block for at most WriteWait, after which
+	// the client is considered too slow and the connection is closed.
+	SendQueue int
+
+	// WriteWait is the time allowed to write a message to the client (10s by default)
+	WriteWait time.Duration
+
+	// PingInterval is the interval at which the client is pinged (30s by default).
+	// Connections not answering for twice as long are closed.
+	PingInterval time.Duration
+
+	// Subprotocols are the supported protocols in order of preference
+	Subprotocols []string
+
+	// CheckOrigin reports whether upgrade request origin is allowed. By default
+	// same origin requests are allowed, along with ones allowed by CORS adapter.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// RegisterWebSocket registers GET endpoint accepting websocket connections.
+// Adapters are applied to upgrade requests.
+func (b *BaseService) RegisterWebSocket(path string, ws WebSocket, a ...RouteOption) {
+	b.RegisterHandler(http.MethodGet, path, ws.handler(), a...)
+}
+
+func (ws WebSocket) handler() HandlerFunc {
+	if ws.ReadLimit == 0 {
+		ws.ReadLimit = 1 << 20
+	}
+	if ws.SendQueue == 0 {
+		ws.SendQueue = 16
+	}
+	if ws.WriteWait == 0 {
+		ws.WriteWait = 10 * time.Second
+	}
+	if ws.PingInterval == 0 {
+		ws.PingInterval = 30 * time.Second
+	}
+
+	return func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		up := websocket.Upgrader{
+			Subprotocols: ws.Subprotocols,
+			CheckOrigin:  ws.CheckOrigin,
+			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+				respond.WithJSON(w, r, NewError(status, reason))
+			},
+		}
+		if up.CheckOrigin == nil {
+			up.CheckOrigin = func(r *http.Request) bool { return originAllowed(w, r) }
+		}
+
+		wsc, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		c, cancel := context.WithCancel(context.WithValue(c, contextReqKey, r))
+		defer cancel()
+
+		conn := &Conn{
+			ws:        wsc,
+			ctx:       c,
+			cancel:    cancel,
+			in:        make(chan []byte, ws.SendQueue),
+			out:       make(chan []byte, ws.SendQueue),
+			closing:   make(chan struct{}),
+			done:      make(chan struct{}),
+			writeWait: ws.WriteWait,
+		}
+
+		srv := serverFromCtx(c)
+		if srv != nil {
+			srv.conns.add(conn)
+			defer srv.conns.remove(conn)
+		}
+
+		wsc.SetReadLimit(ws.ReadLimit)
+		pongWait := 2 * ws.PingInterval
+		wsc.SetReadDeadline(time.Now().Add(pongWait))
+		wsc.SetPongHandler(func(string) error {
+			return wsc.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		go conn.readLoop(pongWait)
+		go conn.writeLoop(ws.PingInterval)
+
+		if srv != nil {
+			go func() {
+				select {
+				case <-srv.closing:
+					conn.close(websocket.CloseGoingAway, "server shutting down")
+				case <-c.Done():
+				}
+			}()
+		}
+
+		err = ws.Handler(c, conn)
+
+		switch {
+		case err == nil || errors.Is(err, ErrConnClosed):
+			conn.close(websocket.CloseNormalClosure, "")
+		case errors.Is(err, ErrSlowConsumer):
+			conn.close(websocket.ClosePolicyViolation, "client too slow")
+		default:
+			reason := "internal error"
+			if e, ok := err.(*Error); ok {
+				reason = strings.TrimSpace(e.Error())
+			}
+			conn.close(websocket.CloseInternalServerErr, reason)
+		}
+
+		<-conn.done
+	}
+}
+
+// originAllowed reports whether upgrade request comes from the same origin,
+// or its origin has been allowed by CORS adapter
+func originAllowed(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed == "*" || allowed == origin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Conn represents websocket connection. Messages can be read
+// and written concurrently, but not by multiple goroutines each.
+type Conn struct {
+	ws        *websocket.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	in        chan []byte
+	out       chan []byte
+	writeWait time.Duration
+
+	mu        sync.Mutex
+	err       error
+	closeCode int
+	closeText string
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// Subprotocol returns protocol negotiated with the client
+func (c *Conn) Subprotocol() string { return c.ws.Subprotocol() }
+
+// ReadJSON reads next message from the client json decoding it into v.
+// It returns ErrConnClosed once the connection is closed.
+func (c *Conn) ReadJSON(v interface{}) error {
+	select {
+	case msg, ok := <-c.in:
+		if !ok {
+			return c.error()
+		}
+		if err := json.Unmarshal(msg, v); err != nil {
+			return fmt.Errorf("http: could not decode websocket message: %v", err)
+		}
+		return nil
+	case <-c.ctx.Done():
+		return c.error()
+	}
+}
+
+// WriteJSON queues json encoded v to be written to the client. It blocks
+// while the queue is full, closing the connection if it does not
+// free up in time (see WebSocket.WriteWait), returning ErrSlowConsumer.
+func (c *Conn) WriteJSON(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("http: could not encode websocket message: %v", err)
+	}
+
+	select {
+	case <-c.ctx.Done():
+		return c.error()
+	default:
+	}
+
+	t := time.NewTimer(c.writeWait)
+	defer t.Stop()
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.ctx.Done():
+		return c.error()
+	case <-t.C:
+		c.fail(ErrSlowConsumer)
+		c.close(websocket.ClosePolicyViolation, "client too slow")
+		return ErrSlowConsumer
+	}
+}
+
+// Close closes the connection with normal closure status once queued
+// messages are written (connection is closed once ConnFunc returns as well)
+func (c *Conn) Close() {
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+func (c *Conn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closeCode, c.closeText = code, reason
+		c.mu.Unlock()
+		c.fail(ErrConnClosed)
+		close(c.closing)
+	})
+}
+
+// fail records the error connection failed with, unless
+// it already has one, and cancels its context
+func (c *Conn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.cancel()
+}
+
+func (c *Conn) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return ErrConnClosed
+	}
+	return c.err
+}
+
+func (c *Conn) readLoop(pongWait time.Duration) {
+	defer close(c.in)
+
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				err = ErrConnClosed
+			}
+			c.fail(err)
+			c.close(websocket.CloseNormalClosure, "")
+			return
+		}
+
+		c.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		select {
+		case c.in <- msg:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Conn) writeLoop(ping time.Duration) {
+	defer close(c.done)
+	defer c.ws.Close()
+
+	ticker := time.NewTicker(ping)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-c.out:
+			if !c.write(msg) {
+				return
+			}
+
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.writeWait)); err != nil {
+				c.fail(err)
+				return
+			}
+
+		case <-c.closing:
+			c.mu.Lock()
+			code, text, err := c.closeCode, c.closeText, c.err
+			c.mu.Unlock()
+
+			// Messages queued before closing are still delivered
+			// unless the client is gone or too slow
+			for err == ErrConnClosed && len(c.out) > 0 {
+				if !c.write(<-c.out) {
+					return
+				}
+			}
+
+			deadline := time.Now().Add(c.writeWait)
+			if c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline) == nil {
+				// Wait for the client to acknowledge closing
+				c.ws.SetReadDeadline(deadline)
+				for range c.in {
+				}
+			}
+			return
+		}
+	}
+}
+
+func (c *Conn) write(msg []byte) bool {
+	c.ws.SetWriteDeadline(time.Now().Add(c.writeWait))
+	if err := c.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = ErrSlowConsumer
+		}
+		c.fail(err)
+		return false
+	}
+	return true
+}
+
+// connTracker tracks open websocket connections
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+	empty chan struct{}
+}
+
+func (t *connTracker) add(c *Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = make(map[*Conn]struct{})
+	}
+	t.conns[c] = struct{}{}
+}
+
+func (t *connTracker) remove(c *Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, c)
+	if len(t.conns) == 0 && t.empty != nil {
+		close(t.empty)
+		t.empty = nil
+	}
+}
+
+func (t *connTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+// wait waits for all connections to be closed, or ctx to be done
+func (t *connTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.conns) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.empty == nil {
+		t.empty = make(chan struct{})
+	}
+	empty := t.empty
+	t.mu.Unlock()
+
+	select {
+	case <-empty:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,277 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/adapter"
+)
+
+type wsMsg struct {
+	Text string `json:"text"`
+}
+
+type wsSvc struct {
+	http.BaseService
+}
+
+func (s *wsSvc) Prefix() string { return "ws" }
+
+func TestRegisterWebSocket(t *testing.T) {
+	var requireToken http.Adapter = func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			if r.URL.Query().Get("token") != "secret" {
+				w.WriteHeader(gohttp.StatusUnauthorized)
+				return
+			}
+			h(c, w, r)
+		}
+	}
+
+	slow := make(chan error, 1)
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := wsSvc{}
+	svc.RegisterWebSocket("/echo", http.WebSocket{
+		Subprotocols: []string{"echo.v1"},
+		ReadLimit:    64,
+		Handler: func(c context.Context, conn *http.Conn) error {
+			for {
+				var m wsMsg
+				if err := conn.ReadJSON(&m); err != nil {
+					return err
+				}
+				if m.Text == "fail" {
+					return http.NewError(gohttp.StatusBadRequest, fmt.Errorf("invalid message"))
+				}
+				if err := conn.WriteJSON(wsMsg{Text: "echo: " + m.Text}); err != nil {
+					return err
+				}
+			}
+		},
+	}, requireToken)
+	svc.RegisterWebSocket("/flood", http.WebSocket{
+		SendQueue: 1,
+		WriteWait: 50 * time.Millisecond,
+		Handler: func(c context.Context, conn *http.Conn) error {
+			big := strings.Repeat("x", 1<<20)
+			for {
+				if err := conn.WriteJSON(wsMsg{Text: big}); err != nil {
+					slow <- err
+					return err
+				}
+			}
+		},
+	})
+	s.MustRegisterService(&svc)
+
+	corsSvc := bindSvc{}
+	corsSvc.RegisterWebSocket("/ws", http.WebSocket{
+		Handler: func(c context.Context, conn *http.Conn) error { return nil },
+	}, adapter.WithCORS(adapter.WithCORSAllowOrigins("https://app.example.com")))
+	s.MustRegisterService(&corsSvc)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	t.Run("test echo", func(t *testing.T) {
+		d := websocket.Dialer{Subprotocols: []string{"echo.v1"}}
+		conn, _, err := d.Dial(url+"/ws/echo?token=secret", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		assert.Equal(t, "echo.v1", conn.Subprotocol())
+
+		for _, txt := range []string{"hello", "world"} {
+			assert.NoError(t, conn.WriteJSON(wsMsg{Text: txt}))
+			var m wsMsg
+			assert.NoError(t, conn.ReadJSON(&m))
+			assert.Equal(t, "echo: "+txt, m.Text)
+		}
+
+		assert.NoError(t, conn.WriteJSON(wsMsg{Text: "fail"}))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseInternalServerErr), err)
+		assert.Contains(t, err.Error(), "invalid message")
+	})
+
+	t.Run("test read limit", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(url+"/ws/echo?token=secret", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteJSON(wsMsg{Text: strings.Repeat("x", 100)}))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+	})
+
+	t.Run("test adapters", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url+"/ws/echo", nil)
+		assert.Equal(t, websocket.ErrBadHandshake, err)
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+
+	t.Run("test origin", func(t *testing.T) {
+		cases := []struct {
+			path     string
+			origin   string
+			wantCode int
+		}{
+			{path: "/ws/echo?token=secret", origin: ts.URL, wantCode: 101},
+			{path: "/ws/echo?token=secret", origin: "https://app.example.com", wantCode: 403},
+			{path: "/customers/ws", origin: "https://app.example.com", wantCode: 101},
+			{path: "/customers/ws", origin: "https://evil.example.com", wantCode: 403},
+		}
+		for _, c := range cases {
+			conn, resp, _ := websocket.DefaultDialer.Dial(url+c.path, gohttp.Header{"Origin": {c.origin}})
+			if assert.NotNil(t, resp) {
+				assert.Equal(t, c.wantCode, resp.StatusCode, c.origin)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	})
+
+	t.Run("test slow consumer", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(url+"/ws/flood", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		select {
+		case err := <-slow:
+			assert.Equal(t, http.ErrSlowConsumer, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("slow consumer not detected")
+		}
+	})
+}
+
+func TestRegisterWebSocket_Lifecycle(t *testing.T) {
+	closed := make(chan error, 1)
+
+	s := http.NewServer(http.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	svc := wsSvc{}
+	svc.RegisterWebSocket("/live", http.WebSocket{
+		PingInterval: 20 * time.Millisecond,
+		Handler: func(c context.Context, conn *http.Conn) error {
+			if err := conn.WriteJSON(wsMsg{Text: "hello"}); err != nil {
+				return err
+			}
+			<-c.Done()
+			err := conn.ReadJSON(&wsMsg{})
+			closed <- err
+			return err
+		},
+	})
+	s.MustRegisterService(&svc)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/live"
+
+	dial := func(t *testing.T) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		var m wsMsg
+		assert.NoError(t, conn.ReadJSON(&m))
+		assert.Equal(t, "hello", m.Text)
+		return conn
+	}
+
+	t.Run("test keepalive", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		pings := 0
+		conn.SetPingHandler(func(data string) error {
+			pings++
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+
+		conn.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+		_, _, err := conn.ReadMessage()
+		assert.Contains(t, err.Error(), "timeout")
+		assert.True(t, pings > 2)
+		assert.Len(t, closed, 0)
+
+		conn.Close()
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("client disconnect not detected")
+		}
+	})
+
+	t.Run("test unresponsive client", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		select {
+		case err := <-closed:
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("unresponsive client not detected")
+		}
+	})
+
+	t.Run("test client close", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+		select {
+		case err := <-closed:
+			assert.Equal(t, http.ErrConnClosed, err)
+		case <-time.After(time.Second):
+			t.Fatal("client close not detected")
+		}
+	})
+
+	t.Run("test shutdown", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		// Connections of previous subtests are untracked once their handlers return
+		assert.Eventually(t, func() bool { return s.WebSocketConns() == 1 }, time.Second, time.Millisecond)
+
+		go func() {
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, s.Shutdown(ctx))
+
+		assert.Equal(t, http.ErrConnClosed, <-closed)
+		assert.Equal(t, 0, s.WebSocketConns())
+	})
+}
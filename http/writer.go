@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps http.ResponseWriter recording response status
+// and size, so that adapters (metrics, tracing...) can inspect them
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WrapResponseWriter wraps w into ResponseWriter,
+// returning w itself if it is already wrapped
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// Status returns written response status (0 if nothing has been written yet)
+func (w *ResponseWriter) Status() int { return w.status }
+
+// Size returns the number of body bytes written
+func (w *ResponseWriter) Size() int64 { return w.size }
+
+// WriteHeader records and writes response status
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records and writes response body
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying writer if it supports flushing
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the underlying connection if the writer supports it
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("http: response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer (used by http.ResponseController)
+func (w *ResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
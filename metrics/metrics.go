@@ -0,0 +1,207 @@
+// Package metrics provides prometheus instrumentation for kit http
+// services and tx transactions
+package metrics
+
+import (
+	"context"
+	"fmt"
+	gohttp "net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/tx"
+)
+
+// UnmatchedRoute is used as route label for requests not matching any registered route
+const UnmatchedRoute = "unmatched"
+
+// Option represents metrics option
+type Option func(*Metrics)
+
+// New creates new Metrics registering http and tx collectors
+// (along with go runtime and process collectors) with a new registry
+// unless one is set with WithRegistry.
+//
+// Transactions are recorded once TxHook is registered with tx.RegisterHook.
+func New(opts ...Option) *Metrics {
+	m := Metrics{
+		namespace:   "kit",
+		buckets:     prometheus.DefBuckets,
+		sizeBuckets: prometheus.ExponentialBuckets(128, 4, 8),
+	}
+
+	for _, o := range opts {
+		o(&m)
+	}
+
+	if m.registry == nil {
+		m.registry = prometheus.NewRegistry()
+		m.registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	httpLabels := []string{"method", "status", "route"}
+
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of http requests.",
+	}, httpLabels)
+
+	m.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Http request latency in seconds.",
+		Buckets:   m.buckets,
+	}, httpLabels)
+
+	m.size = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "Http response body size in bytes.",
+		Buckets:   m.sizeBuckets,
+	}, httpLabels)
+
+	m.inFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: m.namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of http requests currently being served.",
+	}, []string{"method", "route"})
+
+	m.txOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Name:      "tx_operations_total",
+		Help:      "Total number of transaction operations (begin, run, commit, rollback).",
+	}, []string{"op", "result"})
+
+	m.txDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Name:      "tx_duration_seconds",
+		Help:      "Transaction operation duration in seconds.",
+		Buckets:   m.buckets,
+	}, []string{"op"})
+
+	m.registry.MustRegister(m.requests, m.duration, m.size, m.inFlight, m.txOps, m.txDuration)
+
+	return &m
+}
+
+// Metrics represents prometheus http and tx metrics
+type Metrics struct {
+	registry    *prometheus.Registry
+	namespace   string
+	buckets     []float64
+	sizeBuckets []float64
+
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	size       *prometheus.HistogramVec
+	inFlight   *prometheus.GaugeVec
+	txOps      *prometheus.CounterVec
+	txDuration *prometheus.HistogramVec
+}
+
+// Adapter returns http adapter recording request count, latency,
+// response size and in-flight requests, labeled by method, status
+// class (2xx, 4xx...) and route template (eg. /customer/details/{id}).
+// It can be used both as server-wide and service adapter.
+func (m *Metrics) Adapter() http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			route := UnmatchedRoute
+			if rt := http.RouteFromCtx(c); rt != nil {
+				route = rt.Path
+			}
+
+			inFlight := m.inFlight.WithLabelValues(r.Method, route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			rw := http.WrapResponseWriter(w)
+			start := time.Now()
+
+			defer func() {
+				status := rw.Status()
+				if status == 0 {
+					status = gohttp.StatusOK
+				}
+				labels := prometheus.Labels{
+					"method": r.Method,
+					"status": fmt.Sprintf("%dxx", status/100),
+					"route":  route,
+				}
+				m.requests.With(labels).Inc()
+				m.duration.With(labels).Observe(time.Since(start).Seconds())
+				m.size.With(labels).Observe(float64(rw.Size()))
+			}()
+
+			h(c, rw, r)
+		}
+	}
+}
+
+// Handler returns http.Handler serving metrics in prometheus text format,
+// which can be mounted on an admin port
+func (m *Metrics) Handler() gohttp.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ServeMetrics serves metrics in prometheus text format, and can be
+// registered with any kit service, eg:
+//
+//	svc.RegisterHandler("GET", "/metrics", m.ServeMetrics)
+func (m *Metrics) ServeMetrics(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+	m.Handler().ServeHTTP(w, r.WithContext(c))
+}
+
+// Registry returns prometheus registry metrics are registered with,
+// so that custom collectors can be added to it
+func (m *Metrics) Registry() *prometheus.Registry { return m.registry }
+
+// TxHook is tx.Hook recording transaction operation counts and durations, eg:
+//
+//	unregister := tx.RegisterHook(m.TxHook)
+func (m *Metrics) TxHook(ctx context.Context, op tx.Op) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		m.txOps.WithLabelValues(string(op), result).Inc()
+		m.txDuration.WithLabelValues(string(op)).Observe(time.Since(start).Seconds())
+	}
+}
+
+// WithRegistry sets prometheus registry to register metrics with
+func WithRegistry(r *prometheus.Registry) Option {
+	return func(m *Metrics) {
+		m.registry = r
+	}
+}
+
+// WithNamespace sets metric names namespace ("kit" by default)
+func WithNamespace(ns string) Option {
+	return func(m *Metrics) {
+		m.namespace = ns
+	}
+}
+
+// WithBuckets sets latency histogram buckets (in seconds)
+func WithBuckets(b ...float64) Option {
+	return func(m *Metrics) {
+		m.buckets = b
+	}
+}
+
+// WithSizeBuckets sets response size histogram buckets (in bytes)
+func WithSizeBuckets(b ...float64) Option {
+	return func(m *Metrics) {
+		m.sizeBuckets = b
+	}
+}
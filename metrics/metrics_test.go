@@ -0,0 +1,102 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/metrics"
+	"github.com/tonto/kit/tx"
+)
+
+type customerSvc struct {
+	http.BaseService
+}
+
+func (s *customerSvc) Prefix() string { return "customer" }
+
+func TestAdapter(t *testing.T) {
+	m := metrics.New(metrics.WithRegistry(prometheus.NewRegistry()))
+
+	srv := http.NewServer(
+		http.WithLogger(log.New(ioutil.Discard, "", 0)),
+		http.WithAdapters(m.Adapter()),
+	)
+
+	svc := customerSvc{}
+	svc.RegisterHandler("GET", "/details/{id}", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		respond.WithJSON(w, r, "john")
+	})
+	svc.RegisterHandler("POST", "/fail", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		respond.WithJSON(w, r, http.NewError(gohttp.StatusBadRequest, fmt.Errorf("bad")))
+	})
+	srv.MustRegisterService(&svc)
+
+	for _, path := range []string{"/customer/details/1", "/customer/details/2"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/customer/fail", nil))
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+
+	out := scrape(m)
+
+	for _, want := range []string{
+		`kit_http_requests_total{method="GET",route="/customer/details/{id}",status="2xx"} 2`,
+		`kit_http_requests_total{method="POST",route="/customer/fail",status="4xx"} 1`,
+		`kit_http_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+		`kit_http_request_duration_seconds_count{method="GET",route="/customer/details/{id}",status="2xx"} 2`,
+		`kit_http_response_size_bytes_sum{method="GET",route="/customer/details/{id}",status="2xx"} 54`,
+		`kit_http_requests_in_flight{method="GET",route="/customer/details/{id}"} 0`,
+	} {
+		assert.Contains(t, out, want)
+	}
+
+	assert.NotContains(t, out, "/customer/details/1")
+}
+
+func TestTx(t *testing.T) {
+	m := metrics.New(metrics.WithRegistry(prometheus.NewRegistry()), metrics.WithNamespace("app"))
+	defer tx.RegisterHook(m.TxHook)()
+
+	repo := repo{}
+	repo.RunTx(context.Background(), func(context.Context) error { return nil })
+	repo.RunTx(context.Background(), func(context.Context) error { return fmt.Errorf("failed") })
+
+	out := scrape(m)
+
+	for _, want := range []string{
+		`app_tx_operations_total{op="begin",result="ok"} 2`,
+		`app_tx_operations_total{op="commit",result="ok"} 1`,
+		`app_tx_operations_total{op="rollback",result="ok"} 1`,
+		`app_tx_operations_total{op="run",result="ok"} 1`,
+		`app_tx_operations_total{op="run",result="error"} 1`,
+		`app_tx_duration_seconds_count{op="run"} 2`,
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func scrape(m *metrics.Metrics) string {
+	w := httptest.NewRecorder()
+	m.ServeMetrics(context.Background(), w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+type repo struct{}
+
+func (r repo) RunTx(ctx context.Context, f func(context.Context) error) error {
+	t, _ := tx.Begin(ctx, func(context.Context) (interface{}, error) { return "tx", nil })
+	return tx.Run(ctx, r, t, f)
+}
+
+func (repo) Commit(*tx.Tx) error   { return nil }
+func (repo) Rollback(*tx.Tx) error { return nil }
+
@@ -0,0 +1,138 @@
+// Package tracing provides OpenTelemetry tracing for kit http
+// services and tx transactions, propagating W3C trace context
+package tracing
+
+import (
+	"context"
+	gohttp "net/http"
+
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/tx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer spans are created with
+const TracerName = "github.com/tonto/kit/tracing"
+
+// Option represents tracing option
+type Option func(*Tracing)
+
+// New creates new Tracing. Spans are created with global otel tracer
+// provider unless one is set with WithTracerProvider, and trace context is
+// propagated with W3C traceparent and tracestate headers unless a
+// different propagator is set with WithPropagator.
+//
+// Transactions are traced once TxHook is registered with tx.RegisterHook.
+func New(opts ...Option) *Tracing {
+	t := Tracing{
+		propagator: propagation.TraceContext{},
+	}
+
+	for _, o := range opts {
+		o(&t)
+	}
+
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+
+	t.tracer = t.provider.Tracer(TracerName)
+
+	return &t
+}
+
+// Tracing represents http and tx tracing
+type Tracing struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+// Adapter returns http adapter starting a server span for each request,
+// continuing the trace found in request headers (if any), and writing
+// trace context to response headers. Span is named after the method and
+// registered route template (eg. GET /customer/details/{id}).
+// Trace id is stored to context, so that it is included in request
+// log lines and error responses.
+func (t *Tracing) Adapter() http.Adapter {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+			c = t.propagator.Extract(c, propagation.HeaderCarrier(r.Header))
+
+			name := r.Method
+			attrs := []attribute.KeyValue{
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			}
+			if rt := http.RouteFromCtx(c); rt != nil {
+				name += " " + rt.Path
+				attrs = append(attrs, attribute.String("http.route", rt.Path))
+			}
+
+			c, span := t.tracer.Start(
+				c, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			t.propagator.Inject(c, propagation.HeaderCarrier(w.Header()))
+
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				c = respond.WithTraceID(c, sc.TraceID().String())
+			}
+
+			rw := http.WrapResponseWriter(w)
+
+			h(c, rw, r.WithContext(c))
+
+			status := rw.Status()
+			if status == 0 {
+				status = gohttp.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= 500 {
+				span.SetStatus(codes.Error, gohttp.StatusText(status))
+			}
+		}
+	}
+}
+
+// TxHook is tx.Hook starting a span for each transaction operation, eg:
+//
+//	unregister := tx.RegisterHook(tr.TxHook)
+func (t *Tracing) TxHook(ctx context.Context, op tx.Op) (context.Context, func(error)) {
+	name := "tx"
+	if op != tx.OpRun {
+		name += "." + string(op)
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// WithTracerProvider sets tracer provider spans are created with
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(t *Tracing) {
+		t.provider = p
+	}
+}
+
+// WithPropagator sets trace context propagator
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracing) {
+		t.propagator = p
+	}
+}
@@ -0,0 +1,113 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+	"github.com/tonto/kit/tracing"
+	"github.com/tonto/kit/tx"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpan = "00f067aa0ba902b7"
+)
+
+type orderSvc struct {
+	http.BaseService
+}
+
+func (s *orderSvc) Prefix() string { return "orders" }
+
+func TestAdapter(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tr := tracing.New(tracing.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))))
+	defer tx.RegisterHook(tr.TxHook)()
+
+	srv := http.NewServer(
+		http.WithLogger(log.New(ioutil.Discard, "", 0)),
+		http.WithAdapters(tr.Adapter()),
+	)
+
+	svc := orderSvc{}
+	svc.RegisterHandler("POST", "/{id}", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		err := repo{}.RunTx(c, func(context.Context) error { return nil })
+		respond.WithJSON(w, r, err)
+	})
+	svc.RegisterHandler("POST", "/{id}/cancel", func(c context.Context, w gohttp.ResponseWriter, r *gohttp.Request) {
+		err := repo{}.RunTx(c, func(context.Context) error { return fmt.Errorf("not found") })
+		respond.WithJSON(w, r, err)
+	})
+	srv.MustRegisterService(&svc)
+
+	req := httptest.NewRequest("POST", "/orders/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpan+"-01")
+	req.Header.Set("tracestate", "vendor=value")
+	w := httptest.NewRecorder()
+
+	srv.ServeHTTP(w, req)
+
+	spans := exp.GetSpans()
+	names := []string{}
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"tx.begin", "tx.commit", "tx", "POST /orders/{id}"}, names)
+
+	server := spans[3]
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, traceID, server.SpanContext.TraceID().String())
+	assert.Equal(t, parentSpan, server.Parent.SpanID().String())
+	assert.Equal(t, "vendor=value", server.SpanContext.TraceState().String())
+
+	assert.Equal(t, server.SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, server.SpanContext.SpanID(), spans[2].Parent.SpanID())
+	assert.Equal(t, spans[2].SpanContext.SpanID(), spans[1].Parent.SpanID())
+
+	assert.Equal(t, "00-"+traceID+"-"+server.SpanContext.SpanID().String()+"-01", w.Header().Get("traceparent"))
+	assert.Equal(t, "vendor=value", w.Header().Get("tracestate"))
+
+	exp.Reset()
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("POST", "/orders/1/cancel", nil))
+
+	spans = exp.GetSpans()
+	assert.Equal(t, "tx.rollback", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+	assert.Equal(t, codes.Error, spans[3].Status.Code)
+
+	var resp struct {
+		Errors  []string `json:"errors"`
+		TraceID string   `json:"trace_id"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	assert.Equal(t, []string{"not found"}, resp.Errors)
+	assert.Equal(t, spans[3].SpanContext.TraceID().String(), resp.TraceID)
+	assert.True(t, strings.HasPrefix(w.Header().Get("traceparent"), "00-"+resp.TraceID))
+}
+
+type repo struct{}
+
+func (r repo) RunTx(ctx context.Context, f func(context.Context) error) error {
+	t, _ := tx.Begin(ctx, func(context.Context) (interface{}, error) { return "tx", nil })
+	return tx.Run(ctx, r, t, f)
+}
+
+func (repo) Commit(*tx.Tx) error   { return nil }
+func (repo) Rollback(*tx.Tx) error { return nil }
@@ -0,0 +1,97 @@
+package tx
+
+import (
+	"context"
+	"sync"
+)
+
+// Op represents transaction operation reported to hooks
+type Op string
+
+const (
+	// OpBegin represents starting client transaction (see Begin)
+	OpBegin Op = "begin"
+
+	// OpRun represents running transaction func including commit or rollback
+	OpRun Op = "run"
+
+	// OpCommit represents commiting transaction
+	OpCommit Op = "commit"
+
+	// OpRollback represents rolling back transaction
+	OpRollback Op = "rollback"
+)
+
+// Hook is called when a transaction operation starts, and is meant to be
+// used for instrumentation such as metrics and tracing. It can return
+// a derived context (passed on to the operation) and a func which
+// is called with operation error (if any) once it completes.
+type Hook func(ctx context.Context, op Op) (context.Context, func(error))
+
+var hooks struct {
+	sync.RWMutex
+	list []*Hook
+}
+
+// RegisterHook registers a hook called for operations of all transactions,
+// returning a func which unregisters it
+func RegisterHook(h Hook) func() {
+	hooks.Lock()
+	defer hooks.Unlock()
+
+	entry := &h
+	hooks.list = append(hooks.list[:len(hooks.list):len(hooks.list)], entry)
+
+	return func() {
+		hooks.Lock()
+		defer hooks.Unlock()
+
+		list := make([]*Hook, 0, len(hooks.list))
+		for _, e := range hooks.list {
+			if e != entry {
+				list = append(list, e)
+			}
+		}
+		hooks.list = list
+	}
+}
+
+// startOp notifies hooks about operation start returning
+// a func that notifies them about its completion
+func startOp(ctx context.Context, op Op) (context.Context, func(error)) {
+	hooks.RLock()
+	list := hooks.list
+	hooks.RUnlock()
+
+	if len(list) == 0 {
+		return ctx, func(error) {}
+	}
+
+	dones := make([]func(error), 0, len(list))
+	for _, h := range list {
+		var done func(error)
+		ctx, done = (*h)(ctx, op)
+		if done != nil {
+			dones = append(dones, done)
+		}
+	}
+
+	return ctx, func(err error) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+// Begin begins client transaction by calling begin func and wraps it into Tx.
+// Transactional implementations should use it in RunTx in
+// order for transaction begin to be reported to hooks.
+func Begin(ctx context.Context, begin func(context.Context) (interface{}, error)) (*Tx, error) {
+	ctx, done := startOp(ctx, OpBegin)
+	clientTx, err := begin(ctx)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(clientTx), nil
+}
@@ -12,14 +12,25 @@ type SQL struct {
 	DB *sql.DB
 }
 
-// RunTx runs f in a SQL transaction
+// RunTx runs f in a SQL transaction. If ctx already carries a transaction
+// started by RunTx on the same DB (eg. by a repository wrapping multiple
+// services, or idempotency adapter), f joins it instead, so that
+// everything is commited together. Failed joined f marks the
+// transaction rollback only.
 func (sql *SQL) RunTx(ctx context.Context, f func(context.Context) error) error {
-	tx, err := sql.DB.BeginTx(ctx, nil)
+	if cur, ok := Current(ctx); ok && cur.source == sql.DB {
+		return cur.join(ctx, f)
+	}
+
+	tx, err := Begin(ctx, func(ctx context.Context) (interface{}, error) {
+		return sql.DB.BeginTx(ctx, nil)
+	})
 	if err != nil {
 		return err
 	}
+	tx.source = sql.DB
 
-	return Run(ctx, sql, Wrap(tx), f)
+	return Run(ctx, sql, tx, f)
 }
 
 // Commit commits sql transaction
@@ -0,0 +1,109 @@
+package tx_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tonto/kit/tx"
+)
+
+func TestSQL_Join(t *testing.T) {
+	cases := []struct {
+		name         string
+		inner        func(context.Context) error
+		otherDB      bool
+		wantErr      error
+		wantBegins   int
+		wantCommits  int
+		wantRollback int
+	}{
+		{
+			name:        "test joined commit",
+			inner:       func(context.Context) error { return nil },
+			wantBegins:  1,
+			wantCommits: 1,
+		},
+		{
+			name:         "test joined failure rolls back",
+			inner:        func(context.Context) error { return fmt.Errorf("insufficient funds") },
+			wantErr:      tx.ErrRollbackOnly,
+			wantBegins:   1,
+			wantRollback: 1,
+		},
+		{
+			name:        "test other db is not joined",
+			inner:       func(context.Context) error { return nil },
+			otherDB:     true,
+			wantBegins:  2,
+			wantCommits: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			drv := &countingDriver{}
+			outer := tx.SQL{DB: drv.open()}
+			inner := outer
+			if c.otherDB {
+				inner = tx.SQL{DB: drv.open()}
+			}
+
+			err := outer.RunTx(context.Background(), func(ctx context.Context) error {
+				// error is deliberately ignored, eg. a handler
+				// responding with it instead of returning it
+				inner.RunTx(ctx, c.inner)
+				return nil
+			})
+
+			assert.Equal(t, c.wantErr, err)
+			assert.Equal(t, c.wantBegins, drv.begins)
+			assert.Equal(t, c.wantCommits, drv.commits)
+			assert.Equal(t, c.wantRollback, drv.rollbacks)
+		})
+	}
+}
+
+// countingDriver is a database/sql driver only counting transactions
+type countingDriver struct {
+	m         sync.Mutex
+	begins    int
+	commits   int
+	rollbacks int
+}
+
+func (d *countingDriver) open() *sql.DB {
+	return sql.OpenDB(connector{d})
+}
+
+func (d *countingDriver) count(n *int) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	*n++
+}
+
+type connector struct{ d *countingDriver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn{c.d}, nil }
+func (c connector) Driver() driver.Driver                        { return nil }
+
+type conn struct{ d *countingDriver }
+
+func (c conn) Prepare(string) (driver.Stmt, error) { return nil, fmt.Errorf("not supported") }
+func (c conn) Close() error                        { return nil }
+func (c conn) Begin() (driver.Tx, error) {
+	c.d.count(&c.d.begins)
+	return c, nil
+}
+func (c conn) Commit() error {
+	c.d.count(&c.d.commits)
+	return nil
+}
+func (c conn) Rollback() error {
+	c.d.count(&c.d.rollbacks)
+	return nil
+}
@@ -57,10 +57,16 @@ func Wrap(clientTx interface{}) *Tx {
 	}
 }
 
+// ErrRollbackOnly is returned by Run when transaction func succeeded but
+// a transaction joined to it failed (see SQL.RunTx), in which case it is rolled back
+var ErrRollbackOnly = errors.New("tx: transaction marked rollback only by joined transaction")
+
 // Tx represents transaction object
 type Tx struct {
-	m        sync.Mutex
-	clientTx interface{}
+	m            sync.Mutex
+	clientTx     interface{}
+	source       interface{}
+	rollbackOnly bool
 }
 
 // Unwrap unwraps the underlying client transaction wrapped by a call to tx.Wrap
@@ -70,6 +76,24 @@ func (tx *Tx) Unwrap() interface{} {
 	return tx.clientTx
 }
 
+// join runs f as a part of already running transaction, marking
+// it rollback only if f fails
+func (tx *Tx) join(ctx context.Context, f func(context.Context) error) error {
+	err := f(ctx)
+	if err != nil {
+		tx.m.Lock()
+		tx.rollbackOnly = true
+		tx.m.Unlock()
+	}
+	return err
+}
+
+func (tx *Tx) isRollbackOnly() bool {
+	tx.m.Lock()
+	defer tx.m.Unlock()
+	return tx.rollbackOnly
+}
+
 // Current extracts Tx object from context if any
 // Returns nil, false if not in a transaction
 func Current(ctx context.Context) (*Tx, bool) {
@@ -90,9 +114,20 @@ func Run(ctx context.Context, t Transactional, tx *Tx, f func(context.Context) e
 	// 	opt(tx)
 	// }
 
+	ctx, done := startOp(ctx, OpRun)
+
 	defer func() {
+		defer func() { done(err) }()
+
+		if err == nil && tx.isRollbackOnly() {
+			err = ErrRollbackOnly
+		}
+
 		if err != nil {
-			if e := t.Rollback(tx); e != nil {
+			_, end := startOp(ctx, OpRollback)
+			e := t.Rollback(tx)
+			end(e)
+			if e != nil {
 				err = errors.Wrap(
 					err,
 					fmt.Sprintf("tx: error rolling back transaction: %v", e),
@@ -101,7 +136,10 @@ func Run(ctx context.Context, t Transactional, tx *Tx, f func(context.Context) e
 			return
 		}
 
-		if e := t.Commit(tx); e != nil {
+		_, end := startOp(ctx, OpCommit)
+		e := t.Commit(tx)
+		end(e)
+		if e != nil {
 			err = errors.Wrap(e, "tx: error commiting transaction")
 		}
 	}()
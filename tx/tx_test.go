@@ -79,3 +79,43 @@ func (rm *repoMock) Commit(tx *tx.Tx) error {
 func (rm *repoMock) Rollback(tx *tx.Tx) error {
 	return rm.RollbackFunc(tx)
 }
+
+func TestHooks(t *testing.T) {
+	var ops []string
+
+	unregister := tx.RegisterHook(func(ctx context.Context, op tx.Op) (context.Context, func(error)) {
+		ops = append(ops, "start "+string(op))
+		return ctx, func(err error) {
+			ops = append(ops, fmt.Sprintf("end %s: %v", op, err))
+		}
+	})
+
+	rm := &repoMock{
+		CommitFunc:   func(*tx.Tx) error { return nil },
+		RollbackFunc: func(*tx.Tx) error { return fmt.Errorf("conn closed") },
+	}
+
+	tx.Begin(context.Background(), func(context.Context) (interface{}, error) { return 64, nil })
+	rm.RunTx(context.Background(), func(context.Context) error { return nil })
+	rm.RunTx(context.Background(), func(context.Context) error { return fmt.Errorf("failed") })
+
+	unregister()
+	rm.RunTx(context.Background(), func(context.Context) error { return nil })
+
+	want := []string{
+		"start begin",
+		"end begin: <nil>",
+		"start run",
+		"start commit",
+		"end commit: <nil>",
+		"end run: <nil>",
+		"start run",
+		"start rollback",
+		"end rollback: conn closed",
+		"end run: tx: error rolling back transaction: conn closed: failed",
+	}
+
+	if fmt.Sprint(ops) != fmt.Sprint(want) {
+		t.Errorf("unexpected hook calls: want: %v got: %v", want, ops)
+	}
+}